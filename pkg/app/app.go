@@ -5,11 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 
 	"github.com/vmkteam/pgmigrator/pkg/migrator"
 
@@ -26,10 +27,37 @@ const (
 	DateFormat        = "2006-01-02 15:04:05"
 )
 
+// Exit codes of check and lint commands.
+const (
+	ExitPending    = 2
+	ExitInvalid    = 3
+	ExitUnfinished = 4
+	ExitIgnored    = 5 // lint: sql files which would never be applied
+)
+
+// ExitError is an error with process exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 type Config struct {
 	Database   *pg.Options
 	App        migrator.Config
-	ConfigFile string `toml:"-"`
+	Env        map[string]toml.Primitive // named environments [Env.<name>], see DecodeConfigFile
+	EnvName    string                    `toml:"-"` // selected environment, empty for base sections
+	Protected  bool                      // commands which change database ask for confirmation, see confirm
+	Yes        bool                      `toml:"-"` // skip confirmation of protected database
+	ConfigFile string                    `toml:"-"`
+	Output     string                    `toml:"-"` // text or json
 }
 
 type App struct {
@@ -47,19 +75,43 @@ func New(rootCmd *cobra.Command, mg *migrator.Migrator, cfg Config) App {
 }
 
 func (a App) Run(ctx context.Context) error {
-	a.rootCmd.AddCommand(a.initCmd(), a.dryRunCmd(ctx), a.lastCmd(ctx), a.planCmd(ctx), a.redoCmd(ctx), a.runCmd(ctx), a.verifyCmd(ctx), a.skipCmd(ctx))
-	a.rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+	a.rootCmd.AddCommand(a.initCmd(), a.dryRunCmd(ctx), a.lastCmd(ctx), a.planCmd(ctx), a.redoCmd(ctx), a.runCmd(ctx), a.verifyCmd(ctx), a.skipCmd(ctx),
+		a.statusCmd(ctx), a.resolveCmd(ctx), a.rollbackCmd(ctx), a.checkCmd(ctx), a.installCmd(ctx), a.newCmd(), a.lintCmd(), a.renameCmd(ctx),
+		a.upgradeTableCmd(ctx), a.historyCmd(ctx))
+	a.rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if a.cfg.Output != OutputText && a.cfg.Output != OutputJSON {
+			return fmt.Errorf("unknown output format %q, use %s or %s", a.cfg.Output, OutputText, OutputJSON)
+		}
+
 		if cmd.Name() == "init" || cmd.Name() == "help" {
-			return
+			return nil
 		}
 
 		if a.mg == nil {
-			log.Fatal("Configuration file was not found. Please create new via `pgmigrator init`")
+			log.Fatalf("Configuration file was not found. Please create new via `pgmigrator init`, set database url via --dsn or %s or set PGDATABASE", EnvDSN)
 		}
+
+		// new and lint do not use database
+		if cmd.Name() == "new" || cmd.Name() == "lint" {
+			return nil
+		}
+
+		a.printTarget()
+		return a.mg.WaitForDB(ctx)
 	}
 	a.rootCmd.SilenceUsage = true
 
-	return a.rootCmd.Execute()
+	err := a.rootCmd.Execute()
+
+	var (
+		ue *migrator.UnfinishedError
+		ie *migrator.InterruptedError
+	)
+	if errors.As(err, &ue) || errors.As(err, &ie) && ie.Unfinished() {
+		printUnfinishedHint(os.Stderr)
+	}
+
+	return err
 }
 
 // initCmd represents the init command.
@@ -97,11 +149,13 @@ func (a App) initCmd() *cobra.Command {
 
 // lastCmd represents the last command.
 func (a App) lastCmd(ctx context.Context) *cobra.Command {
-	return &cobra.Command{
+	var allAttempts bool
+	cmd := &cobra.Command{
 		Use:   "last [<count>]",
 		Short: "Shows recent applied migrations from db",
 		Long: `Shows recent applied migrations from db.
-If <count> applied, shows recent <count> applied migrations. By default: 5`,
+If <count> applied, shows recent <count> applied migrations. By default: 5
+With --all-attempts shows recent attempts to apply migrations from history table, including failed ones.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// calculate count
 			cnt, err := count(args)
@@ -109,23 +163,102 @@ If <count> applied, shows recent <count> applied migrations. By default: 5`,
 				return errors.New("invalid argument")
 			}
 
+			if allAttempts {
+				aa, err := a.mg.LastAttempts(ctx, cnt)
+				if err != nil {
+					return fmt.Errorf("execute command error: %w", err)
+				} else if a.isJSON() {
+					return printJSON(HistoryOutput{Attempts: nonNil(aa)})
+				}
+
+				fmt.Printf("Showing last %d attempts in %s:\n", cnt, migrator.HistoryTable(a.cfg.App.Table))
+				printAttempts(aa, true)
+				return nil
+			}
+
 			mm, err := a.mg.Last(ctx, cnt)
 			if err != nil {
 				return fmt.Errorf("execute command error: %w", err)
 			}
 
+			rr, err := a.mg.LastRepeatable(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			}
+
+			if a.isJSON() {
+				return printJSON(LastOutput{Migrations: nonNil(mm), Repeatable: nonNil(rr)})
+			}
+
 			// print table
-			tbl := table.New("ID", "StartedAt", "FinishedAt", "Duration", "Filename")
+			tbl := table.New("ID", "StartedAt", "FinishedAt", "Duration", "Filename", "AppliedBy", "Version", "Revision")
 			for _, m := range mm {
 				if m.FinishedAt != nil {
-					tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.FinishedAt.Format(DateFormat), m.FinishedAt.Sub(m.StartedAt), m.Filename)
+					tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.FinishedAt.Format(DateFormat), m.FinishedAt.Sub(m.StartedAt), m.Filename, appliedBy(m.DBUser, m.OSUser, m.Hostname), m.Version, m.Revision)
 				} else { // err
-					tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), "error while applying", "", m.Filename)
+					tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), "error while applying", "", m.Filename, appliedBy(m.DBUser, m.OSUser, m.Hostname), m.Version, m.Revision)
 				}
 			}
 
 			fmt.Printf("Showing last %d migrations in %s:\n", cnt, a.cfg.App.Table)
 			prepareTable(tbl).Print()
+			if len(rr) == 0 {
+				return nil
+			}
+
+			fmt.Printf("Showing repeatable migrations in %s:\n", a.cfg.App.Table)
+			tbl = table.New("ID", "StartedAt", "FinishedAt", "Duration", "Filename", "AppliedBy", "Version", "Revision")
+			for _, m := range rr {
+				tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.FinishedAt.Format(DateFormat), m.FinishedAt.Sub(m.StartedAt), m.Filename, appliedBy(m.DBUser, m.OSUser, m.Hostname), m.Version, m.Revision)
+			}
+			prepareTable(tbl).Print()
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&allAttempts, "all-attempts", false, "show recent attempts from history table, including failed")
+
+	return cmd
+}
+
+// historyCmd shows all attempts to apply migration.
+func (a App) historyCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <filename>",
+		Short: "Shows all attempts to apply migration with error details",
+		Long: `Shows all attempts to apply migration by run and redo or undo file by rollback from history table in chronological order.
+Failed attempts are shown with SQLSTATE, error message, detail, hint, error position and failed statement of non-transactional migration.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			aa, err := a.mg.History(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(HistoryOutput{Attempts: nonNil(aa)})
+			} else if len(aa) == 0 {
+				fmt.Printf("Attempts of %s were not found in %s.\n", args[0], migrator.HistoryTable(a.cfg.App.Table))
+				return nil
+			}
+
+			fmt.Printf("Showing %d attempts of %s:\n", len(aa), args[0])
+			printAttempts(aa, false)
+
+			// error details
+			for _, at := range aa {
+				if at.Status == migrator.AttemptDone {
+					continue
+				}
+
+				fmt.Printf("Attempt %d %s: %s\n", at.ID, at.Status, at.Error)
+				printField("SQLSTATE", at.SQLState)
+				printField("Detail", at.Detail)
+				printField("Hint", at.Hint)
+				if at.Position > 0 {
+					printField("Position", strconv.Itoa(at.Position))
+				}
+				if at.Statement > 0 {
+					printField("Statement", strconv.Itoa(at.Statement))
+				}
+			}
 			return nil
 		},
 	}
@@ -141,18 +274,49 @@ func (a App) planCmd(ctx context.Context) *cobra.Command {
 			mm, err := a.mg.Plan(ctx)
 			if err != nil {
 				return fmt.Errorf("execute command failed: %w", err)
-			} else if len(mm) == 0 {
+			}
+
+			rr, err := a.mg.PlanRepeatable(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command failed: %w", err)
+			}
+
+			ff, err := a.mg.IgnoredFiles()
+			if err != nil {
+				return fmt.Errorf("execute command failed: %w", err)
+			}
+
+			oo, err := a.mg.OutOfOrder(ctx, mm)
+			if err != nil {
+				return fmt.Errorf("execute command failed: %w", err)
+			}
+
+			if a.isJSON() {
+				return a.printPlanJSON(mm, rr, ff, oo)
+			}
+
+			defer printIgnored(ff)
+			if len(mm) == 0 && len(rr) == 0 {
 				fmt.Println("No new migrations were found.")
 				return nil
 			}
 
 			// print table
-			fmt.Printf("Planning to apply %d migrations:\n", len(mm))
-			tbl := table.New("ID", "Filename")
-			for i, m := range mm {
-				tbl.AddRow(i+1, m)
+			if len(mm) > 0 {
+				if err = a.printPlan(mm); err != nil {
+					return err
+				}
+				a.printOutOfOrder(oo)
+			}
+
+			if len(rr) > 0 {
+				fmt.Printf("Planning to apply %d repeatable migrations after all migrations:\n", len(rr))
+				tbl := table.New("ID", "Filename")
+				for i, m := range rr {
+					tbl.AddRow(i+1, m)
+				}
+				prepareTable(tbl).Print()
 			}
-			prepareTable(tbl).Print()
 			return nil
 		},
 	}
@@ -163,24 +327,28 @@ func (a App) verifyCmd(ctx context.Context) *cobra.Command {
 	return &cobra.Command{
 		Use:   "verify",
 		Short: "Checks and shows invalid migrations",
-		Long:  ``,
+		Long: `Checks and shows applied migrations with changed md5sum, applied migrations without local files
+and likely renamed migrations (local file with the same md5sum was not applied).`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			mm, err := a.mg.Verify(ctx)
+			res, err := a.mg.Verify(ctx)
 			if err != nil {
 				return fmt.Errorf("execute command error: %w", err)
-			} else if len(mm) == 0 {
-				fmt.Println("All applied migrations are correct!")
-				return nil
 			}
 
-			// print table
-			fmt.Printf("Found %d invalid applied migrations:\n", len(mm))
-			tbl := table.New("ID", "StartedAt", "Filename", "MD5sum (applied)", "MD5sum (local)")
-			for _, m := range mm {
-				tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.Filename, m.Md5sum, m.Md5sumLocal)
+			ff, err := a.mg.IgnoredFiles()
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(VerifyOutput{Invalid: nonNil(res.Invalid), Missing: nonNil(res.Missing), Renamed: nonNil(res.Renamed), Ignored: nonNil(ff)})
 			}
 
-			prepareTable(tbl).Print()
+			defer printIgnored(ff)
+			if len(res.Invalid) == 0 && len(res.Missing) == 0 && len(res.Renamed) == 0 {
+				fmt.Println("All applied migrations are correct!")
+				return nil
+			}
+
+			printVerifyResult(res)
 			return nil
 		},
 	}
@@ -198,9 +366,13 @@ If <count> applied, applies only <count> migrations from plan. By default: 5`,
 			mm, err := a.mg.Plan(ctx)
 			if err != nil {
 				return fmt.Errorf("execute command failed: %w", err)
-			} else if len(mm) == 0 {
-				fmt.Println("No new migrations were found.")
-				return nil
+			}
+
+			rr, err := a.mg.PlanRepeatable(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command failed: %w", err)
+			} else if len(mm) == 0 && len(rr) == 0 {
+				return a.noMigrations()
 			}
 
 			// calculate count
@@ -211,15 +383,18 @@ If <count> applied, applies only <count> migrations from plan. By default: 5`,
 				cnt = len(mm)
 			}
 
-			fmt.Println("Running live migrations:")
+			if err = a.confirm("run", runFiles(mm, rr, cnt)); err != nil {
+				return err
+			}
+
+			a.println("Running live migrations:")
 			// apply migrations
-			ch := make(chan string)
-			wg := &sync.WaitGroup{}
-			go readCh(ch, wg)
-			if err = a.mg.Run(ctx, mm[:cnt], ch); err != nil {
+			t := a.newTracker(StatusDone)
+			err = a.mg.Run(ctx, mm[:cnt], t)
+			a.printInterrupted(mm[:cnt], t.results, err)
+			if err = a.printResults(t.results, err); err != nil {
 				return fmt.Errorf("apply migration error: %w", err)
 			}
-			wg.Wait()
 			return nil
 		},
 	}
@@ -238,8 +413,7 @@ If <count> applied, runs only <count> migrations. By default: 5`,
 			if err != nil {
 				return fmt.Errorf("execute command failed: %w", err)
 			} else if len(mm) == 0 {
-				fmt.Println("No new migrations were found.")
-				return nil
+				return a.noMigrations()
 			}
 
 			// calculate count
@@ -250,16 +424,14 @@ If <count> applied, runs only <count> migrations. By default: 5`,
 				cnt = len(mm)
 			}
 
-			fmt.Println("BEGIN")
+			a.println("BEGIN")
 			// apply migrations
-			ch := make(chan string)
-			wg := &sync.WaitGroup{}
-			go readCh(ch, wg)
-			if err = a.mg.DryRun(ctx, mm[:cnt], ch); err != nil {
+			t := a.newTracker(StatusDone)
+			err = a.mg.DryRun(ctx, mm[:cnt], t)
+			if err = a.printResults(t.results, err); err != nil {
 				return fmt.Errorf("apply migration error: %w", err)
 			}
-			wg.Wait()
-			fmt.Println("ROLLBACK")
+			a.println("ROLLBACK")
 			return nil
 		},
 	}
@@ -278,8 +450,7 @@ If <count> applied, marks only first <count> migrations displayed in plan. Defau
 			if err != nil {
 				return fmt.Errorf("execute command failed: %w", err)
 			} else if len(mm) == 0 {
-				fmt.Println("No new migrations were found.")
-				return nil
+				return a.noMigrations()
 			}
 
 			// calculate count
@@ -290,16 +461,18 @@ If <count> applied, marks only first <count> migrations displayed in plan. Defau
 				cnt = len(mm)
 			}
 
+			if err = a.confirm("skip", mm[:cnt]); err != nil {
+				return err
+			}
+
 			// skip migrations
-			ch := make(chan string)
-			wg := &sync.WaitGroup{}
-			go readCh(ch, wg)
-			fmt.Println("Skipping migrations...")
-			if err = a.mg.Skip(ctx, mm[:cnt], ch); err != nil {
+			a.println("Skipping migrations...")
+			t := a.newTracker(StatusSkipped)
+			err = a.mg.Skip(ctx, mm[:cnt], t)
+			if err = a.printResults(t.results, err); err != nil {
 				return fmt.Errorf("skip migration error: %w", err)
 			}
-			wg.Wait()
-			fmt.Println("Done")
+			a.println("Done")
 			return nil
 		},
 	}
@@ -312,20 +485,649 @@ func (a App) redoCmd(ctx context.Context) *cobra.Command {
 		Short: "Rerun last applied migration from db",
 		Long:  ``,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("Redo last migration:")
-			ch := make(chan string)
-			wg := &sync.WaitGroup{}
-			go readCh(ch, wg)
-			_, err := a.mg.Redo(ctx, ch)
-			if err != nil {
+			if err := a.confirmRedo(ctx); err != nil {
+				return err
+			}
+
+			a.println("Redo last migration:")
+			t := a.newTracker(StatusDone)
+			_, err := a.mg.Redo(ctx, t)
+			if err = a.printResults(t.results, err); err != nil {
 				return fmt.Errorf("apply migration error: %w", err)
 			}
-			wg.Wait()
 			return nil
 		},
 	}
 }
 
+// installCmd creates migrations table.
+func (a App) installCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "install",
+		Short: "Creates migrations table in db",
+		Long: `Creates migrations table in db if not exists.
+Read-only commands (plan, last, verify, status, check) never create migrations table.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			created, err := a.mg.Install(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(InstallOutput{Created: created})
+			}
+
+			if created {
+				fmt.Printf("Migrations table %s was successfully created.\n", a.cfg.App.Table)
+			} else {
+				fmt.Printf("Migrations table %s already exists.\n", a.cfg.App.Table)
+			}
+			return nil
+		},
+	}
+}
+
+// upgradeTableCmd upgrades schema of migrations table.
+func (a App) upgradeTableCmd(ctx context.Context) *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "upgrade-table",
+		Short: "Upgrades migrations table schema to the latest version",
+		Long: `Creates migrations table or upgrades its schema (columns, defaults, comments) to the latest version.
+Schema versions are stored in schema table (migrations table with "Schema" suffix), all changes are idempotent.
+Run, skip, redo, rollback and resolve upgrade migrations table automatically.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, changes, err := a.mg.SchemaPlan(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			}
+
+			if !dryRun && len(changes) > 0 {
+				if err = a.confirm("upgrade-table", nil); err != nil {
+					return err
+				}
+				if changes, err = a.mg.UpgradeTable(ctx); err != nil {
+					return fmt.Errorf("upgrade migrations table error: %w", err)
+				}
+			}
+
+			if a.isJSON() {
+				return printJSON(UpgradeTableOutput{Version: version, LatestVersion: migrator.SchemaVersion, Changes: changes, Applied: !dryRun && len(changes) > 0})
+			} else if len(changes) == 0 {
+				fmt.Printf("Migrations table %s is up to date, schema version %d.\n", a.cfg.App.Table, version)
+				return nil
+			}
+
+			if dryRun {
+				fmt.Printf("Planning to upgrade migrations table %s from schema version %d to %d:\n", a.cfg.App.Table, version, migrator.SchemaVersion)
+			} else {
+				fmt.Printf("Migrations table %s was upgraded from schema version %d to %d:\n", a.cfg.App.Table, version, migrator.SchemaVersion)
+			}
+			for _, ch := range changes {
+				fmt.Printf("  Version %d: %s\n", ch.Version, ch.Description)
+				for _, q := range ch.Queries {
+					fmt.Printf("    %s;\n", strings.Join(strings.Fields(q), " "))
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show statements to upgrade migrations table")
+
+	return cmd
+}
+
+// newCmd creates new migration file.
+func (a App) newCmd() *cobra.Command {
+	var nonTransactional, manual bool
+	cmd := &cobra.Command{
+		Use:   "new <description>",
+		Short: "Creates new migration file",
+		Long: `Creates new migration file with today's date, next sequence number and description,
+e.g. "pgmigrator new create users table" creates 2022-12-13-01-create-users-table.sql.
+Use --nontr for non-transactional migration and --manual for manual migration.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var suffix string
+			if nonTransactional {
+				suffix = migrator.SuffixNonTransactional
+			} else if manual {
+				suffix = migrator.SuffixManual
+			}
+
+			filename, err := a.mg.NewMigrationFile(strings.Join(args, " "), suffix)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(NewOutput{Filename: filename})
+			}
+
+			fmt.Printf("File %s was successfully created.\n", filename)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&nonTransactional, "nontr", false, "create non-transactional migration")
+	cmd.Flags().BoolVar(&manual, "manual", false, "create manual migration, which is ignored by pgmigrator")
+	cmd.MarkFlagsMutuallyExclusive("nontr", "manual")
+
+	return cmd
+}
+
+// statusCmd shows applied, pending and unfinished migrations.
+func (a App) statusCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Shows count of applied and pending migrations and unfinished migrations",
+		Long:  ``,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := a.mg.Status(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			} else if a.isJSON() {
+				st.Pending, st.Unfinished = nonNil(st.Pending), nonNil(st.Unfinished)
+				return printJSON(st)
+			}
+
+			if !st.Initialized {
+				fmt.Printf("Migrations table %s does not exist, create it via `pgmigrator install` or `pgmigrator run`.\n", a.cfg.App.Table)
+			}
+
+			fmt.Printf("Migrations table %s: %d applied, %d pending.\n", a.cfg.App.Table, st.Applied, len(st.Pending))
+			if len(st.Unfinished) == 0 {
+				fmt.Println("No unfinished migrations were found.")
+				return nil
+			}
+
+			// print table
+			fmt.Printf("Found %d unfinished migrations:\n", len(st.Unfinished))
+			tbl := table.New("ID", "StartedAt", "Filename", "Status")
+			for _, m := range st.Unfinished {
+				tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.Filename, color.RedString("UNFINISHED"))
+			}
+			prepareTable(tbl).Print()
+			printUnfinishedHint(os.Stdout)
+			return nil
+		},
+	}
+}
+
+// resolveCmd resolves unfinished non-transactional migration.
+func (a App) resolveCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resolve <done|retry> <filename>",
+		Short: "Resolves unfinished non-transactional migration",
+		Long: `Resolves unfinished non-transactional migration, which failed or was interrupted.
+done - marks migration as finished, use it if all statements were applied (e.g. manually).
+retry - deletes migration from db, so it will be applied again by run command.`,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{string(migrator.ResolveDone), string(migrator.ResolveRetry)},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action, filename := migrator.ResolveAction(args[0]), args[1]
+			if err := a.confirm("resolve", []string{filename}); err != nil {
+				return err
+			}
+
+			pm, err := a.mg.Resolve(ctx, filename, action)
+			if err != nil {
+				return fmt.Errorf("resolve migration error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(ResolveOutput{Action: action, Migration: pm})
+			}
+
+			if action == migrator.ResolveDone {
+				fmt.Printf("Migration %s was marked as finished.\n", filename)
+			} else {
+				fmt.Printf("Migration %s was deleted from %s, it will be applied again by `pgmigrator run`.\n", filename, a.cfg.App.Table)
+			}
+			return nil
+		},
+	}
+}
+
+// rollbackCmd reverts last applied migrations using undo files.
+func (a App) rollbackCmd(ctx context.Context) *cobra.Command {
+	var planOnly bool
+	cmd := &cobra.Command{
+		Use:   "rollback [<count>]",
+		Short: "Reverts last applied migrations using undo files",
+		Long: `Reverts last applied migrations using undo files (e.g. 2022-12-13-01-create-categories-table.down.sql).
+Undo files run in reverse order, each inside transaction with deleting migration from db.
+If <count> applied, reverts <count> last migrations. By default: 1`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cnt := 1
+			if len(args) > 0 {
+				var err error
+				if cnt, err = strconv.Atoi(args[0]); err != nil || cnt < 1 {
+					return errors.New("invalid argument, count must be positive")
+				}
+			}
+
+			dm, err := a.mg.RollbackPlan(ctx, cnt)
+			if err != nil {
+				return fmt.Errorf("execute command failed: %w", err)
+			} else if a.isJSON() {
+				return a.rollbackJSON(ctx, cnt, dm, planOnly)
+			} else if len(dm) == 0 {
+				fmt.Println("No applied migrations were found.")
+				return nil
+			}
+
+			// print table
+			fmt.Printf("Planning to revert %d migrations:\n", len(dm))
+			tbl := table.New("ID", "Filename", "Undo file")
+			for _, m := range dm {
+				undo := m.DownFilename
+				if undo == "" {
+					undo = color.RedString("not found")
+				}
+				tbl.AddRow(m.ID, m.Filename, undo)
+			}
+			prepareTable(tbl).Print()
+			if planOnly {
+				return nil
+			}
+
+			if err = a.confirm("rollback", downFilenames(dm)); err != nil {
+				return err
+			}
+
+			fmt.Println("Reverting migrations:")
+			_, err = a.mg.Rollback(ctx, cnt, a.newTracker(StatusReverted))
+			if err != nil {
+				return fmt.Errorf("rollback migration error: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&planOnly, "plan", false, "only show migrations to revert")
+
+	return cmd
+}
+
+// runFiles returns migrations which are applied by run with count cnt.
+// Repeatable migrations are applied only after all pending migrations, see Migrator.Run.
+func runFiles(pending, repeatable []string, cnt int) []string {
+	if cnt < len(pending) {
+		return pending[:cnt]
+	}
+
+	return slices.Concat(pending, repeatable)
+}
+
+// checkCmd checks migrations and exits with non-zero code if something is wrong.
+func (a App) checkCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Checks that all migrations are applied and valid, for CI and readiness checks",
+		Long: fmt.Sprintf(`Checks that there are no pending, invalid by md5sum and unfinished migrations.
+Never creates migrations table, so it can be used with read-only role.
+Exit codes: 0 - ok, %d - pending migrations, %d - checksum mismatch, %d - unfinished migrations, 1 - other errors.
+If there are several problems, the biggest code is used.`, ExitPending, ExitInvalid, ExitUnfinished),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := a.mg.Check(ctx)
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			}
+
+			if a.isJSON() {
+				res.Pending, res.PendingRepeatable = nonNil(res.Pending), nonNil(res.PendingRepeatable)
+				res.Invalid, res.Unfinished = nonNil(res.Invalid), nonNil(res.Unfinished)
+				if err = printJSON(res); err != nil {
+					return err
+				}
+			} else {
+				printCheckResult(res)
+			}
+
+			return checkError(res)
+		},
+	}
+}
+
+// printPlan prints migrations from plan with its directives as table.
+func (a App) printPlan(filenames []string) error {
+	mm, err := a.mg.ReadMigrations(filenames)
+	if err != nil {
+		return fmt.Errorf("execute command failed: %w", err)
+	}
+
+	fmt.Printf("Planning to apply %d migrations:\n", len(mm))
+	tbl := table.New("ID", "Filename", "Transactional", "Directives")
+	for i, m := range mm {
+		tbl.AddRow(i+1, m.Filename, m.Transactional, m.Directives())
+	}
+	prepareTable(tbl).Print()
+
+	return nil
+}
+
+// printOutOfOrder prints out-of-order migrations with its position among applied migrations.
+func (a App) printOutOfOrder(oo []migrator.OutOfOrderMigration) {
+	if len(oo) == 0 {
+		return
+	}
+
+	policy := a.cfg.App.OutOfOrder
+	if policy == "" {
+		policy = migrator.OutOfOrderAllow
+	}
+
+	fmt.Printf("Found %d out-of-order migrations, they sort before already applied migrations (OutOfOrder = %s):\n", len(oo), policy)
+	tbl := table.New("Filename", "After applied", "Before applied")
+	for _, o := range oo {
+		tbl.AddRow(o.Filename, o.After, o.Before)
+	}
+	prepareTable(tbl).Print()
+
+	if policy == migrator.OutOfOrderDeny {
+		fmt.Println(color.RedString("Run will fail, rename migrations or change OutOfOrder policy."))
+	}
+}
+
+// printVerifyResult prints invalid, missing and renamed migrations as tables.
+func printVerifyResult(res *migrator.VerifyResult) {
+	if len(res.Invalid) > 0 {
+		fmt.Printf("Found %d invalid applied migrations:\n", len(res.Invalid))
+		tbl := table.New("ID", "StartedAt", "Filename", "MD5sum (applied)", "MD5sum (local)")
+		for _, m := range res.Invalid {
+			tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.Filename, m.Md5sum, m.Md5sumLocal)
+		}
+		prepareTable(tbl).Print()
+	}
+
+	if len(res.Missing) > 0 {
+		fmt.Printf("Found %d applied migrations without local files:\n", len(res.Missing))
+		tbl := table.New("ID", "StartedAt", "Filename", "MD5sum (applied)")
+		for _, m := range res.Missing {
+			tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.Filename, m.Md5sum)
+		}
+		prepareTable(tbl).Print()
+	}
+
+	if len(res.Renamed) > 0 {
+		fmt.Printf("Found %d likely renamed migrations:\n", len(res.Renamed))
+		tbl := table.New("ID", "StartedAt", "Filename (applied)", "Filename (local)")
+		for _, m := range res.Renamed {
+			tbl.AddRow(m.ID, m.StartedAt.Format(DateFormat), m.Filename, m.LocalFilename)
+		}
+		prepareTable(tbl).Print()
+		fmt.Println("Use `pgmigrator rename <applied> <local>` if files were renamed on purpose, otherwise they will be applied again.")
+	}
+}
+
+// renameCmd updates filename of applied migration.
+func (a App) renameCmd(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <old filename> <new filename>",
+		Short: "Updates filename of applied migration after renaming its file",
+		Long: `Updates filename of applied migration in db after its file was renamed on purpose,
+so renamed file is not applied again. New file must exist and must not be applied.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.confirm("rename", []string{args[0]}); err != nil {
+				return err
+			}
+
+			pm, err := a.mg.Rename(ctx, args[0], args[1])
+			if err != nil {
+				return fmt.Errorf("rename migration error: %w", err)
+			} else if a.isJSON() {
+				return printJSON(RenameOutput{OldFilename: args[0], Migration: pm})
+			}
+
+			fmt.Printf("Migration %s was renamed to %s.\n", args[0], args[1])
+			return nil
+		},
+	}
+}
+
+// printAttempts prints attempts as table.
+func printAttempts(aa []migrator.PgAttempt, withFilename bool) {
+	tbl := table.New("ID", "StartedAt", "Duration", "Status", "SQLSTATE", "AppliedBy", "Version", "Revision")
+	if withFilename {
+		tbl = table.New("ID", "StartedAt", "Duration", "Filename", "Status", "SQLSTATE", "AppliedBy", "Version", "Revision")
+	}
+
+	for _, at := range aa {
+		status := string(at.Status)
+		if at.Status != migrator.AttemptDone {
+			status = color.RedString(status)
+		}
+
+		row := []any{at.ID, at.StartedAt.Format(DateFormat), at.Duration(), status, at.SQLState, appliedBy(at.DBUser, at.OSUser, at.Hostname), at.Version, at.Revision}
+		if withFilename {
+			row = slices.Insert(row, 3, any(at.Filename))
+		}
+		tbl.AddRow(row...)
+	}
+	prepareTable(tbl).Print()
+}
+
+// printField prints non-empty field of attempt.
+func printField(name, value string) {
+	if value != "" {
+		fmt.Printf("  %-10s %s\n", name+":", value)
+	}
+}
+
+// appliedBy returns database role, OS user and hostname of applied migration, e.g. "app (deploy@ci-runner)".
+func appliedBy(dbUser, osUser, hostname string) string {
+	s := dbUser
+	if osUser != "" || hostname != "" {
+		s += fmt.Sprintf(" (%s@%s)", osUser, hostname)
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// printIgnored prints ignored sql files as table.
+func printIgnored(ff []migrator.IgnoredFile) {
+	if len(ff) == 0 {
+		return
+	}
+
+	fmt.Printf("Ignored %d sql files:\n", len(ff))
+	tbl := table.New("Filename", "Reason")
+	for _, f := range ff {
+		tbl.AddRow(f.Filename, f.Reason)
+	}
+	prepareTable(tbl).Print()
+}
+
+// lintCmd checks sql files which would never be applied.
+func (a App) lintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint",
+		Short: "Checks sql files which would never be applied, for CI",
+		Long: fmt.Sprintf(`Checks sql files in migrations directory which would never be applied:
+files which do not match file mask and files in subdirectories (except repeatable dir).
+MANUAL migrations are ignored on purpose and are not reported. Works without db.
+Exit codes: 0 - ok, %d - found files which would never be applied, 1 - other errors.`, ExitIgnored),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ff, err := a.mg.Lint()
+			if err != nil {
+				return fmt.Errorf("execute command error: %w", err)
+			}
+
+			if a.isJSON() {
+				if err = printJSON(LintOutput{Files: nonNil(ff)}); err != nil {
+					return err
+				}
+			} else if len(ff) == 0 {
+				fmt.Println("All sql files will be applied!")
+			} else {
+				printIgnored(ff)
+			}
+
+			if len(ff) > 0 {
+				return &ExitError{Code: ExitIgnored, Err: fmt.Errorf("found %d sql files which would never be applied", len(ff))}
+			}
+
+			return nil
+		},
+	}
+}
+
+// printCheckResult prints check result as text.
+func printCheckResult(res *migrator.CheckResult) {
+	if !res.Initialized {
+		fmt.Println("Migrations table does not exist.")
+	}
+
+	if res.OK() {
+		fmt.Println("All migrations are applied and correct!")
+		return
+	}
+
+	for _, f := range res.Pending {
+		fmt.Printf("  - %s \t%s\n", f, color.YellowString("pending"))
+	}
+	for _, f := range res.PendingRepeatable {
+		fmt.Printf("  - %s \t%s\n", f, color.YellowString("pending repeatable"))
+	}
+	for _, m := range res.Invalid {
+		fmt.Printf("  - %s \t%s\n", m.Filename, color.RedString("md5sum mismatch: applied %s, local %s", m.Md5sum, m.Md5sumLocal))
+	}
+	for _, m := range res.Unfinished {
+		fmt.Printf("  - %s \t%s\n", m.Filename, color.RedString("unfinished"))
+	}
+}
+
+// checkError returns ExitError with the biggest exit code for check result.
+func checkError(res *migrator.CheckResult) error {
+	switch {
+	case len(res.Unfinished) > 0:
+		return &ExitError{Code: ExitUnfinished, Err: fmt.Errorf("found %d unfinished migrations", len(res.Unfinished))}
+	case len(res.Invalid) > 0:
+		return &ExitError{Code: ExitInvalid, Err: fmt.Errorf("found %d invalid applied migrations", len(res.Invalid))}
+	case len(res.Pending) > 0 || len(res.PendingRepeatable) > 0:
+		return &ExitError{Code: ExitPending, Err: fmt.Errorf("found %d pending migrations", len(res.Pending)+len(res.PendingRepeatable))}
+	}
+
+	return nil
+}
+
+// rollbackJSON prints rollback plan and results in json.
+func (a App) rollbackJSON(ctx context.Context, cnt int, dm []migrator.DownMigration, planOnly bool) error {
+	out := RollbackOutput{Plan: nonNil(dm), RunOutput: newRunOutput(nil, nil)}
+	if planOnly || len(dm) == 0 {
+		return printJSON(out)
+	} else if err := a.confirm("rollback", downFilenames(dm)); err != nil {
+		return err
+	}
+
+	t := a.newTracker(StatusReverted)
+	_, err := a.mg.Rollback(ctx, cnt, t)
+	out.RunOutput = newRunOutput(t.results, err)
+	if er := printJSON(out); er != nil {
+		return er
+	} else if err != nil {
+		return fmt.Errorf("rollback migration error: %w", err)
+	}
+
+	return nil
+}
+
+// printPlanJSON prints pending migrations and repeatable migrations in json.
+func (a App) printPlanJSON(filenames, repeatable []string, ignored []migrator.IgnoredFile, outOfOrder []migrator.OutOfOrderMigration) error {
+	mm, err := a.mg.ReadMigrations(filenames)
+	if err != nil {
+		return fmt.Errorf("execute command failed: %w", err)
+	}
+
+	rr, err := a.mg.ReadMigrations(repeatable)
+	if err != nil {
+		return fmt.Errorf("execute command failed: %w", err)
+	}
+
+	return printJSON(PlanOutput{Migrations: newMigrationFiles(mm), Repeatable: newMigrationFiles(rr), Ignored: nonNil(ignored), OutOfOrder: nonNil(outOfOrder)})
+}
+
+// printResults prints results of run, dryrun, skip and redo commands in json. It returns err or json encoding error.
+func (a App) printResults(results []Result, err error) error {
+	if !a.isJSON() {
+		return err
+	}
+
+	if er := printJSON(newRunOutput(results, err)); er != nil {
+		return er
+	}
+
+	return err
+}
+
+// printInterrupted prints applied and not applied migrations if run was interrupted.
+func (a App) printInterrupted(filenames []string, results []Result, err error) {
+	var ie *migrator.InterruptedError
+	if a.isJSON() || !errors.As(err, &ie) {
+		return
+	}
+
+	done := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		if r.Status == StatusDone {
+			done[r.Filename] = struct{}{}
+		}
+	}
+
+	var rest []string
+	for _, filename := range filenames {
+		if _, ok := done[filename]; !ok {
+			rest = append(rest, filename)
+		}
+	}
+
+	fmt.Println(color.YellowString("Interrupted: %d of %d migrations were applied.", len(filenames)-len(rest), len(filenames)))
+	switch {
+	case ie.Unfinished():
+		fmt.Printf("Migration %s was left unfinished, some of its statements may be applied.\n", ie.Filename)
+	case ie.Filename != "":
+		fmt.Printf("Migration %s was rolled back.\n", ie.Filename)
+	}
+
+	if len(rest) > 0 {
+		fmt.Println("Not applied:")
+		for _, filename := range rest {
+			fmt.Printf("  - %s\n", filename)
+		}
+	}
+}
+
+// noMigrations prints that no new migrations were found.
+func (a App) noMigrations() error {
+	if a.isJSON() {
+		return printJSON(newRunOutput(nil, nil))
+	}
+
+	fmt.Println("No new migrations were found.")
+	return nil
+}
+
+// printTarget prints selected environment and target database in text output.
+func (a App) printTarget() {
+	env := a.cfg.EnvName
+	if env == "" {
+		env = "default"
+	}
+
+	a.println(fmt.Sprintf("Environment: %s, database: %s at %s", env, a.cfg.Database.Database, a.cfg.Database.Addr))
+}
+
+// println prints text output only.
+func (a App) println(s string) {
+	if !a.isJSON() {
+		fmt.Println(s)
+	}
+}
+
+// printUnfinishedHint explains what unfinished migration is and how to resolve it.
+func printUnfinishedHint(w io.Writer) {
+	fmt.Fprintln(w, `Unfinished migration is a non-transactional migration which failed or was interrupted,
+some of its statements may be applied. New migrations will not be applied until it is resolved.
+Check the database state and then:
+  - run "pgmigrator resolve done <filename>" if migration was completely applied (e.g. manually);
+  - run "pgmigrator resolve retry <filename>" to apply it again with "pgmigrator run".`)
+}
+
 func prepareTable(tbl table.Table) table.Table {
 	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgYellow).SprintfFunc()
@@ -342,22 +1144,11 @@ func count(args []string) (int, error) {
 	return strconv.Atoi(args[0])
 }
 
-func readCh(ch chan string, wg *sync.WaitGroup) {
-	wg.Add(1)
-	var lastTime time.Time
-	for x := range ch {
-		// first run
-		if !lastTime.IsZero() {
-			fmt.Printf("done in %v\n", time.Since(lastTime))
-		}
-
-		fmt.Printf("  - %s \t...", x)
-		lastTime = time.Now()
-	}
-
-	if !lastTime.IsZero() {
-		fmt.Printf("done in %v\n", time.Since(lastTime))
+// nonNil returns empty slice instead of nil for json output.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
 	}
 
-	wg.Done()
+	return s
 }
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vmkteam/pgmigrator/pkg/migrator"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Run(t *testing.T) {
+	t.Run("unknown output format", func(t *testing.T) {
+		rootCmd := &cobra.Command{Use: "pgmigrator", SilenceErrors: true}
+		rootCmd.SetArgs([]string{"lint"})
+
+		a := New(rootCmd, nil, Config{Output: "yaml"})
+		require.EqualError(t, a.Run(context.Background()), `unknown output format "yaml", use text or json`)
+	})
+
+	t.Run("lint exit code", func(t *testing.T) {
+		rootCmd := &cobra.Command{Use: "pgmigrator", SilenceErrors: true}
+		rootCmd.SetArgs([]string{"lint"})
+
+		fsys := fstest.MapFS{"2023-1-01-foo.sql": {Data: []byte("select 1;")}}
+		mg := migrator.NewMigratorFS(nil, migrator.NewDefaultConfig(), fsys)
+
+		a := New(rootCmd, mg, Config{Output: OutputJSON})
+		var exitErr *ExitError
+		require.ErrorAs(t, a.Run(context.Background()), &exitErr)
+		assert.Equal(t, ExitIgnored, exitErr.Code)
+	})
+}
+
+func TestRunFiles(t *testing.T) {
+	pending := []string{"2022-12-12-01-create-table.sql", "2022-12-12-02-create-index.sql"}
+	repeatable := []string{"repeatable/v-news.sql"}
+
+	assert.Equal(t, pending[:1], runFiles(pending, repeatable, 1))
+	assert.Equal(t, append(pending, repeatable...), runFiles(pending, repeatable, 2))
+	assert.Equal(t, repeatable, runFiles(nil, repeatable, 0))
+}
@@ -0,0 +1,295 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-pg/pg/v10"
+)
+
+// Environment variables.
+const (
+	EnvDSN      = "PGMIGRATOR_DSN"      // database connection URL, see --dsn flag
+	EnvRevision = "PGMIGRATOR_REVISION" // git commit or CI job id, see --revision flag
+)
+
+// reEnvVar matches ${NAME} in configuration file.
+var reEnvVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}`)
+
+// DecodeConfigFile reads configuration file into cfg. ${NAME} outside of comments is replaced by environment variable NAME,
+// undefined variable is an error.
+// If env is not empty, [Env.<env>.Database] and [Env.<env>.App] sections override keys of base [Database] and [App] sections.
+func DecodeConfigFile(filename, env string, cfg *Config) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if data, err = interpolateEnv(data); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	md, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	} else if env == "" {
+		return nil
+	}
+
+	prim, ok := cfg.Env[env]
+	if !ok {
+		names := slices.Sorted(maps.Keys(cfg.Env))
+		return fmt.Errorf(`%s: environment "%s" was not found, available: %s`, filename, env, strings.Join(names, ", "))
+	}
+
+	if err = md.PrimitiveDecode(prim, cfg); err != nil {
+		return fmt.Errorf("%s: environment %s: %w", filename, env, err)
+	}
+	cfg.EnvName = env
+
+	return nil
+}
+
+// interpolateEnv replaces ${NAME} by environment variable NAME, comments are not changed.
+func interpolateEnv(data []byte) ([]byte, error) {
+	var undefined []string
+	replace := func(b []byte) []byte {
+		name := string(reEnvVar.FindSubmatch(b)[1])
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return []byte(v)
+	}
+
+	var (
+		res       []byte
+		multiline string // delimiter of multiline string which continues on next line
+	)
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		i := commentStart(line, &multiline)
+		if i < 0 {
+			i = len(line)
+		}
+
+		res = append(res, reEnvVar.ReplaceAllFunc(line[:i], replace)...)
+		res = append(res, line[i:]...)
+	}
+
+	if len(undefined) > 0 {
+		return nil, fmt.Errorf("environment variables are not set: %s", strings.Join(undefined, ", "))
+	}
+
+	return res, nil
+}
+
+// commentStart returns index of # comment in TOML line or -1, # inside strings is skipped.
+// Multiline is a delimiter of multiline string (three quotes) opened on previous lines, it is updated for next line.
+func commentStart(line []byte, multiline *string) int {
+	var quote byte // quote of single line string
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case *multiline != "":
+			if c == '\\' && *multiline == `"""` {
+				i++
+			} else if bytes.HasPrefix(line[i:], []byte(*multiline)) {
+				i += 2
+				*multiline = ""
+			}
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case bytes.HasPrefix(line[i:], []byte(`"""`)) || bytes.HasPrefix(line[i:], []byte(`'''`)):
+			*multiline = string(line[i : i+3])
+			i += 2
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ResolveDatabase fills database connection options. Precedence from highest:
+// dsn (--dsn flag or PGMIGRATOR_DSN), [Database] section of configuration file,
+// libpq environment variables (PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE, PGSSLMODE),
+// password file (PGPASSFILE or ~/.pgpass).
+// DSN replaces address, user, password, database and sslmode, other options of [Database] section are kept.
+func (c *Config) ResolveDatabase(dsn string) error {
+	// default user is OS user like in libpq only without [Database] section,
+	// configuration files with the section keep default user of go-pg
+	defaultUser := "postgres"
+	if c.Database == nil {
+		c.Database = &pg.Options{}
+		defaultUser = osUser()
+	}
+	opt := c.Database
+
+	if dsn != "" {
+		u, err := pg.ParseURL(dsn)
+		if err != nil {
+			return fmt.Errorf("invalid dsn: %w", err)
+		}
+
+		opt.Addr, opt.User, opt.Password, opt.Database, opt.TLSConfig = u.Addr, u.User, u.Password, u.Database, u.TLSConfig
+		if u.ApplicationName != "" {
+			opt.ApplicationName = u.ApplicationName
+		}
+		if u.DialTimeout != 0 {
+			opt.DialTimeout = u.DialTimeout
+		}
+	}
+
+	// libpq environment variables
+	if opt.Addr == "" {
+		opt.Addr = net.JoinHostPort(envOr("PGHOST", "localhost"), envOr("PGPORT", "5432"))
+	}
+	if opt.User == "" {
+		opt.User = envOr("PGUSER", defaultUser)
+	}
+	if opt.Database == "" {
+		opt.Database = os.Getenv("PGDATABASE")
+	}
+	if opt.Password == "" {
+		opt.Password = os.Getenv("PGPASSWORD")
+	}
+	if mode := os.Getenv("PGSSLMODE"); dsn == "" && opt.TLSConfig == nil && mode != "" {
+		u, err := pg.ParseURL("postgres://localhost/postgres?sslmode=" + mode)
+		if err != nil {
+			return fmt.Errorf("invalid PGSSLMODE: %w", err)
+		}
+		opt.TLSConfig = u.TLSConfig
+	}
+
+	// password file
+	if opt.Password == "" {
+		password, err := pgpassPassword(opt)
+		if err != nil {
+			return err
+		}
+		opt.Password = password
+	}
+
+	return nil
+}
+
+// envOr returns environment variable or default value if it is empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// osUser returns name of current OS user, it is default database user like in libpq.
+func osUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return "postgres"
+}
+
+// pgpassPassword returns password for opt from PGPASSFILE or ~/.pgpass.
+// Like libpq, file is ignored if it is readable by group or others.
+func pgpassPassword(opt *pg.Options) (string, error) {
+	filename := os.Getenv("PGPASSFILE")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		filename = filepath.Join(home, ".pgpass")
+	}
+
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("read password file failed: %w", err)
+	} else if fi.Mode().Perm()&0o077 != 0 {
+		log.Printf("WARNING: password file %s has group or world access; permissions should be u=rw (0600) or less", filename)
+		return "", nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("read password file failed: %w", err)
+	}
+
+	host, port, err := net.SplitHostPort(opt.Addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %s: %w", opt.Addr, err)
+	}
+
+	want := []string{host, port, opt.Database, opt.User}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		if pgpassMatch(fields[:4], want) {
+			return fields[4], nil
+		}
+	}
+
+	return "", nil
+}
+
+// splitPgpassLine splits line of password file by colons, "\:" and "\\" are escaped colon and backslash.
+func splitPgpassLine(line string) []string {
+	var (
+		fields []string
+		sb     strings.Builder
+	)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			sb.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return append(fields, sb.String())
+}
+
+// pgpassMatch reports whether host, port, database and user fields of password file match, * matches any value.
+func pgpassMatch(fields, want []string) bool {
+	for i := range fields {
+		if fields[i] != "*" && fields[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmkteam/pgmigrator/pkg/migrator"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// confirm asks to type database name before command changes protected database, see Config.Protected.
+// Target and files are printed to stderr, so json output is not affected.
+// Confirmation is not needed with --yes, without it command is refused if stdin is not a terminal.
+func (a App) confirm(command string, files []string) error {
+	if !a.cfg.Protected || a.cfg.Yes {
+		return nil
+	}
+
+	opt := a.cfg.Database
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("database %s at %s is protected, use --yes to %s in non-interactive mode", opt.Database, opt.Addr, command)
+	}
+
+	w := os.Stderr
+	fmt.Fprintln(w, color.RedString("Database is protected, %s will change it.", command))
+	if a.cfg.EnvName != "" {
+		fmt.Fprintf(w, "  Environment: %s\n", a.cfg.EnvName)
+	}
+	fmt.Fprintf(w, "  Host:        %s\n", opt.Addr)
+	fmt.Fprintf(w, "  Database:    %s\n", opt.Database)
+	fmt.Fprintf(w, "  User:        %s\n", opt.User)
+	fmt.Fprintf(w, "  Table:       %s\n", a.cfg.App.Table)
+	if len(files) > 0 {
+		fmt.Fprintln(w, "  Migrations:")
+		for _, f := range files {
+			fmt.Fprintf(w, "    - %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "Type database name to continue: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("read confirmation failed: %w", err)
+	} else if strings.TrimSpace(line) != opt.Database {
+		return errors.New("confirmation failed: database name does not match")
+	}
+
+	return nil
+}
+
+// confirmRedo asks confirmation for redo of last applied migration.
+func (a App) confirmRedo(ctx context.Context) error {
+	if !a.cfg.Protected || a.cfg.Yes {
+		return nil
+	}
+
+	mm, err := a.mg.Last(ctx, 1)
+	if err != nil {
+		return fmt.Errorf("execute command error: %w", err)
+	}
+
+	files := make([]string, 0, len(mm))
+	for _, pm := range mm {
+		files = append(files, pm.Filename)
+	}
+
+	return a.confirm("redo", files)
+}
+
+// downFilenames returns undo files of migrations.
+func downFilenames(dm []migrator.DownMigration) []string {
+	files := make([]string, 0, len(dm))
+	for _, d := range dm {
+		files = append(files, d.DownFilename)
+	}
+
+	return files
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmkteam/pgmigrator/pkg/migrator"
+)
+
+// Output formats.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Statuses of migration file in run results.
+const (
+	StatusDone        = "done"
+	StatusSkipped     = "skipped"
+	StatusReverted    = "reverted"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// MigrationFile is a migration file in json output of plan command.
+type MigrationFile struct {
+	Filename         string   `json:"filename"`
+	Transactional    bool     `json:"transactional"`
+	Md5sum           string   `json:"md5sum"`
+	StatementTimeout string   `json:"statementTimeout,omitempty"`
+	LockTimeout      string   `json:"lockTimeout,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+}
+
+// Result is a result of processing migration file by run, dryrun, skip, redo and rollback commands.
+type Result struct {
+	Filename   string   `json:"filename"`
+	Status     string   `json:"status"`
+	DurationMs int64    `json:"durationMs"`
+	Error      string   `json:"error,omitempty"`
+	Notices    []string `json:"notices,omitempty"`
+}
+
+// PlanOutput is a json output of plan command.
+type PlanOutput struct {
+	Migrations []MigrationFile                `json:"migrations"`
+	Repeatable []MigrationFile                `json:"repeatable"`
+	Ignored    []migrator.IgnoredFile         `json:"ignored"`
+	OutOfOrder []migrator.OutOfOrderMigration `json:"outOfOrder"`
+}
+
+// LastOutput is a json output of last command.
+type LastOutput struct {
+	Migrations []migrator.PgMigration `json:"migrations"`
+	Repeatable []migrator.PgMigration `json:"repeatable"`
+}
+
+// HistoryOutput is a json output of history and last --all-attempts commands.
+type HistoryOutput struct {
+	Attempts []migrator.PgAttempt `json:"attempts"`
+}
+
+// VerifyOutput is a json output of verify command.
+type VerifyOutput struct {
+	Invalid []migrator.PgMigration      `json:"invalid"`
+	Missing []migrator.PgMigration      `json:"missing"`
+	Renamed []migrator.RenamedMigration `json:"renamed"`
+	Ignored []migrator.IgnoredFile      `json:"ignored"`
+}
+
+// LintOutput is a json output of lint command.
+type LintOutput struct {
+	Files []migrator.IgnoredFile `json:"files"`
+}
+
+// RunOutput is a json output of run, dryrun, skip, redo and rollback commands.
+type RunOutput struct {
+	Migrations []Result `json:"migrations"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// RollbackOutput is a json output of rollback command.
+type RollbackOutput struct {
+	Plan []migrator.DownMigration `json:"plan"`
+	RunOutput
+}
+
+// NewOutput is a json output of new command.
+type NewOutput struct {
+	Filename string `json:"filename"`
+}
+
+// InstallOutput is a json output of install command.
+type InstallOutput struct {
+	Created bool `json:"created"`
+}
+
+// UpgradeTableOutput is a json output of upgrade-table command.
+type UpgradeTableOutput struct {
+	Version       int                     `json:"version"`
+	LatestVersion int                     `json:"latestVersion"`
+	Changes       []migrator.SchemaChange `json:"changes"`
+	Applied       bool                    `json:"applied"`
+}
+
+// RenameOutput is a json output of rename command.
+type RenameOutput struct {
+	OldFilename string                `json:"oldFilename"`
+	Migration   *migrator.PgMigration `json:"migration"`
+}
+
+// ResolveOutput is a json output of resolve command.
+type ResolveOutput struct {
+	Action    migrator.ResolveAction `json:"action"`
+	Migration *migrator.PgMigration  `json:"migration"`
+}
+
+func (a App) isJSON() bool {
+	return a.cfg.Output == OutputJSON
+}
+
+// printJSON writes v as indented json to stdout.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode json failed: %w", err)
+	}
+
+	return nil
+}
+
+// newMigrationFiles converts migrations to json output.
+func newMigrationFiles(mm migrator.Migrations) []MigrationFile {
+	res := make([]MigrationFile, 0, len(mm))
+	for _, m := range mm {
+		res = append(res, MigrationFile{
+			Filename:         m.Filename,
+			Transactional:    m.Transactional,
+			Md5sum:           m.Md5Sum,
+			StatementTimeout: m.StatementTimeout,
+			LockTimeout:      m.LockTimeout,
+			Tags:             m.Tags,
+		})
+	}
+
+	return res
+}
+
+// newRunOutput returns json output with results and error.
+func newRunOutput(results []Result, err error) RunOutput {
+	out := RunOutput{Migrations: results}
+	if out.Migrations == nil {
+		out.Migrations = []Result{}
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	return out
+}
+
+// tracker receives migration events, prints progress and collects results.
+type tracker struct {
+	status  string
+	print   bool
+	results []Result
+}
+
+// newTracker returns migration observer, status is used for successfully processed files.
+func (a App) newTracker(status string) *tracker {
+	return &tracker{
+		status: status,
+		print:  !a.isJSON(),
+	}
+}
+
+func (t *tracker) OnEvent(e migrator.Event) {
+	switch e.Type {
+	case migrator.EventStarted:
+		t.results = append(t.results, Result{Filename: e.Filename})
+		t.printf("  - %s \t...", e.Filename)
+	case migrator.EventStatement:
+		if e.Total > 1 {
+			t.printf(" [%d/%d]", e.Statement, e.Total)
+		}
+	case migrator.EventNotice:
+		if len(t.results) == 0 {
+			return
+		}
+
+		r := &t.results[len(t.results)-1]
+		r.Notices = append(r.Notices, e.Message)
+		t.printf("\n    %s", strings.ReplaceAll(e.Message, "\n", "\n    "))
+	case migrator.EventFinished, migrator.EventFailed:
+		if len(t.results) == 0 {
+			return
+		}
+
+		r := &t.results[len(t.results)-1]
+		r.DurationMs = e.Duration.Milliseconds()
+		r.Status = t.status
+		var ie *migrator.InterruptedError
+		if errors.As(e.Err, &ie) {
+			r.Status = StatusInterrupted
+			r.Error = e.Err.Error()
+		} else if e.Err != nil {
+			r.Status = StatusFailed
+			r.Error = e.Err.Error()
+		}
+		if len(r.Notices) > 0 {
+			// status is printed on new line after notices
+			t.printf("\n   ")
+		}
+		t.printf(" %s in %v\n", r.Status, e.Duration)
+	case migrator.EventSkipped:
+		t.results = append(t.results, Result{Filename: e.Filename, Status: StatusSkipped})
+		t.printf("  - %s \t%s\n", e.Filename, StatusSkipped)
+	}
+}
+
+// printf prints progress in text output.
+func (t *tracker) printf(format string, a ...any) {
+	if t.print {
+		fmt.Printf(format, a...)
+	}
+}
@@ -0,0 +1,49 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"os/user"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// auditInfo is who applies migrations and from where, it is saved with each migration record.
+// Database role is saved by default value of "dbUser" column.
+type auditInfo struct {
+	osUser   string
+	hostname string
+	version  string
+	revision string
+}
+
+// newAuditInfo returns audit info of current process.
+func newAuditInfo(cfg Config) auditInfo {
+	a := auditInfo{version: cfg.Version, revision: cfg.Revision}
+	if u, err := user.Current(); err == nil {
+		a.osUser = u.Username
+	}
+	a.hostname, _ = os.Hostname()
+
+	return a
+}
+
+// toDB returns migration record with audit info.
+func (m *Migrator) toDB(mg Migration) *PgMigration {
+	pm := mg.ToDB()
+	pm.OSUser = m.audit.osUser
+	pm.Hostname = m.audit.hostname
+	pm.Version = m.audit.version
+	pm.Revision = m.audit.revision
+
+	return pm
+}
+
+// selectModel returns query which selects all columns of migrations table instead of model columns,
+// so read-only commands work with table which was not upgraded yet, see UpgradeTable.
+func (m *Migrator) selectModel(ctx context.Context, model any) *orm.Query {
+	return m.db.ModelContext(ctx, model).ColumnExpr("?TableAlias.*")
+}
+
+// auditColumns are columns added to migrations table by schema version 2.
+var auditColumns = []string{"dbUser", "osUser", "hostname", "version", "revision"}
@@ -0,0 +1,59 @@
+package migrator
+
+import (
+	"context"
+)
+
+// CheckResult is a result of Check.
+type CheckResult struct {
+	Initialized       bool          `json:"initialized"` // migrations table exists
+	Pending           []string      `json:"pending"`
+	PendingRepeatable []string      `json:"pendingRepeatable"`
+	Invalid           []PgMigration `json:"invalid"`
+	Unfinished        []PgMigration `json:"unfinished"`
+}
+
+// OK reports whether there are no pending, invalid and unfinished migrations.
+func (r CheckResult) OK() bool {
+	return len(r.Pending) == 0 && len(r.PendingRepeatable) == 0 && len(r.Invalid) == 0 && len(r.Unfinished) == 0
+}
+
+// Check returns pending, invalid by md5sum and unfinished migrations. It never creates migrations table,
+// so it can be used with read-only role. If migrations table does not exist, all migrations are pending.
+func (m *Migrator) Check(ctx context.Context) (*CheckResult, error) {
+	var (
+		res CheckResult
+		err error
+	)
+
+	if res.Initialized, err = m.tableExists(ctx); err != nil {
+		return nil, err
+	} else if !res.Initialized {
+		if res.Pending, err = m.readAllFiles(); err != nil {
+			return nil, err
+		}
+
+		res.PendingRepeatable, err = m.readRepeatableFiles()
+		return &res, err
+	}
+
+	if res.Unfinished, err = m.unfinished(ctx); err != nil {
+		return nil, err
+	}
+
+	if res.Pending, err = m.plan(ctx); err != nil {
+		return nil, err
+	}
+
+	if res.PendingRepeatable, err = m.planRepeatableFiles(ctx); err != nil {
+		return nil, err
+	}
+
+	vr, err := m.verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res.Invalid = vr.Invalid
+
+	return &res, nil
+}
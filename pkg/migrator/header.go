@@ -0,0 +1,119 @@
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directivePrefix starts directives line in leading comment block of migration file:
+//
+//	-- pgmigrator: transactional=false statement_timeout=30m lock_timeout=3s tags=billing
+const directivePrefix = "pgmigrator:"
+
+// Directive keys.
+const (
+	DirectiveTransactional    = "transactional"
+	DirectiveStatementTimeout = "statement_timeout"
+	DirectiveLockTimeout      = "lock_timeout"
+	DirectiveTags             = "tags"
+)
+
+var directiveKeys = []string{DirectiveTransactional, DirectiveStatementTimeout, DirectiveLockTimeout, DirectiveTags}
+
+// parseHeader parses directives from leading comment block of migration.
+// Directives override NONTR suffix, StatementTimeout and LockTimeout from config.
+func (m *Migration) parseHeader() error {
+	// lines are cut without bufio.Scanner, it fails on lines longer than 64KB (e.g. generated data)
+	rest := m.Data
+	for line := 1; len(rest) > 0; line++ {
+		var b []byte
+		b, rest, _ = bytes.Cut(rest, []byte("\n"))
+
+		s := strings.TrimSpace(string(b))
+		if s == "" {
+			continue
+		} else if !strings.HasPrefix(s, "--") {
+			// end of leading comment block
+			break
+		}
+
+		s = strings.TrimSpace(strings.TrimPrefix(s, "--"))
+		if !strings.HasPrefix(s, directivePrefix) {
+			continue
+		}
+
+		for _, d := range strings.Fields(strings.TrimPrefix(s, directivePrefix)) {
+			key, value, ok := strings.Cut(d, "=")
+			if !ok || value == "" {
+				return fmt.Errorf(`line %d: invalid directive "%s", use key=value`, line, d)
+			}
+
+			if err := m.setDirective(key, value); err != nil {
+				return fmt.Errorf(`line %d: %w`, line, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Migration) setDirective(key, value string) error {
+	switch key {
+	case DirectiveTransactional:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf(`invalid %s "%s", use true or false`, key, value)
+		}
+		m.Transactional = v
+	case DirectiveStatementTimeout, DirectiveLockTimeout:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf(`invalid %s "%s", use duration like 500ms, 30s or 5m`, key, value)
+		}
+
+		if key == DirectiveStatementTimeout {
+			m.StatementTimeout = value
+		} else {
+			m.LockTimeout = value
+		}
+	case DirectiveTags:
+		m.Tags = strings.Split(value, ",")
+	default:
+		return fmt.Errorf(`unknown directive "%s", known directives: %s`, key, strings.Join(directiveKeys, ", "))
+	}
+
+	return nil
+}
+
+// Directives returns directives of migration in header format, e.g. for plan output.
+func (m *Migration) Directives() string {
+	var dd []string
+	if m.Transactional == strings.HasSuffix(m.Filename, "NONTR.sql") {
+		dd = append(dd, fmt.Sprintf("%s=%t", DirectiveTransactional, m.Transactional))
+	}
+	if m.StatementTimeout != "" {
+		dd = append(dd, fmt.Sprintf("%s=%s", DirectiveStatementTimeout, m.StatementTimeout))
+	}
+	if m.LockTimeout != "" {
+		dd = append(dd, fmt.Sprintf("%s=%s", DirectiveLockTimeout, m.LockTimeout))
+	}
+	if len(m.Tags) > 0 {
+		dd = append(dd, fmt.Sprintf("%s=%s", DirectiveTags, strings.Join(m.Tags, ",")))
+	}
+
+	return strings.Join(dd, " ")
+}
+
+// pgDuration converts go duration to PostgreSQL duration in milliseconds.
+func pgDuration(s string) string {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		// config value is passed to PostgreSQL as is, e.g. "5s" or "1min"
+		return s
+	}
+
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
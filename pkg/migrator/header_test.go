@@ -0,0 +1,105 @@
+package migrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigration_parseHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     Migration
+		wantErr  string
+	}{
+		{
+			name:     "without header",
+			filename: "2022-12-12-01-create-table.sql",
+			data:     "-- create table\nCREATE TABLE t (id int);",
+			want:     Migration{Transactional: true},
+		},
+		{
+			name:     "all directives",
+			filename: "2022-12-12-01-create-index.sql",
+			data: `-- create index on news
+-- pgmigrator: transactional=false statement_timeout=30m
+--pgmigrator: lock_timeout=3s tags=billing,news
+
+CREATE INDEX CONCURRENTLY ON news (title);
+-- pgmigrator: transactional=true`,
+			want: Migration{StatementTimeout: "30m", LockTimeout: "3s", Tags: []string{"billing", "news"}},
+		},
+		{
+			name:     "override NONTR suffix",
+			filename: "2022-12-12-01-comments-NONTR.sql",
+			data:     "-- pgmigrator: transactional=true",
+			want:     Migration{Transactional: true},
+		},
+		{
+			name:     "unknown directive",
+			filename: "2022-12-12-01-create-table.sql",
+			data:     "\n-- pgmigrator: timeout=5s",
+			wantErr:  `line 2: unknown directive "timeout", known directives: transactional, statement_timeout, lock_timeout, tags`,
+		},
+		{
+			name:     "invalid value",
+			filename: "2022-12-12-01-create-table.sql",
+			data:     "-- pgmigrator: statement_timeout=5min",
+			wantErr:  `line 1: invalid statement_timeout "5min", use duration like 500ms, 30s or 5m`,
+		},
+		{
+			name:     "invalid format",
+			filename: "2022-12-12-01-create-table.sql",
+			data:     "-- pgmigrator: transactional",
+			wantErr:  `line 1: invalid directive "transactional", use key=value`,
+		},
+		{
+			name:     "long line",
+			filename: "2022-12-12-01-insert-data.sql",
+			data:     "-- pgmigrator: lock_timeout=3s\n-- " + strings.Repeat("x", 100_000) + "\n-- pgmigrator: tags=data\nINSERT INTO t VALUES ('" + strings.Repeat("y", 100_000) + "');",
+			want:     Migration{Transactional: true, LockTimeout: "3s", Tags: []string{"data"}},
+		},
+		{
+			name:     "crlf line endings",
+			filename: "2022-12-12-01-create-table.sql",
+			data:     "-- pgmigrator: transactional=false\r\nCREATE TABLE t (id int);\r\n",
+			want:     Migration{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Migration{Filename: tt.filename, Data: []byte(tt.data), Transactional: !strings.HasSuffix(tt.filename, "NONTR.sql")}
+
+			err := m.parseHeader()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Transactional, m.Transactional)
+			assert.Equal(t, tt.want.StatementTimeout, m.StatementTimeout)
+			assert.Equal(t, tt.want.LockTimeout, m.LockTimeout)
+			assert.Equal(t, tt.want.Tags, m.Tags)
+		})
+	}
+}
+
+func TestMigration_Directives(t *testing.T) {
+	m := Migration{Filename: "2022-12-12-01-create-index.sql", StatementTimeout: "30m", Tags: []string{"billing"}}
+	assert.Equal(t, "transactional=false statement_timeout=30m tags=billing", m.Directives())
+
+	m = Migration{Filename: "2022-12-12-01-create-index-NONTR.sql"}
+	assert.Empty(t, m.Directives())
+}
+
+func TestPgDuration(t *testing.T) {
+	assert.Equal(t, "5000ms", pgDuration("5s"))
+	assert.Equal(t, "1800000ms", pgDuration("30m"))
+	assert.Equal(t, "1min", pgDuration("1min"))
+}
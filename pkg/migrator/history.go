@@ -0,0 +1,176 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// AttemptStatus is a result of migration attempt.
+type AttemptStatus string
+
+const (
+	AttemptDone        AttemptStatus = "done"
+	AttemptFailed      AttemptStatus = "failed"
+	AttemptInterrupted AttemptStatus = "interrupted"
+)
+
+// PgAttempt is an attempt to apply migration by run or redo or undo file by rollback, it is saved to history table
+// (migrations table with "History" suffix) whether migration was applied or not.
+type PgAttempt struct {
+	tableName struct{} `pg:"?historyTable,alias:t,discard_unknown_columns"` //nolint:all
+
+	ID            int           `pg:"id,pk" json:"id"`
+	Filename      string        `pg:"filename,use_zero" json:"filename"`
+	Status        AttemptStatus `pg:"status,use_zero" json:"status"`
+	StartedAt     time.Time     `pg:"startedAt,use_zero" json:"startedAt"`
+	DurationMs    int64         `pg:"durationMs,use_zero" json:"durationMs"`
+	Transactional bool          `pg:"transactional,use_zero" json:"transactional"`
+	Md5sum        string        `pg:"md5sum,use_zero" json:"md5sum"`
+
+	// error details, empty for done attempts
+	SQLState  string `pg:"sqlState" json:"sqlState,omitempty"`
+	Error     string `pg:"error" json:"error,omitempty"`
+	Detail    string `pg:"detail" json:"detail,omitempty"`
+	Hint      string `pg:"hint" json:"hint,omitempty"`
+	Position  int    `pg:"position" json:"position,omitempty"`   // position of error in statement, starts from 1
+	Statement int    `pg:"statement" json:"statement,omitempty"` // failed statement of non-transactional migration, starts from 1
+
+	// audit, see PgMigration
+	DBUser   string `pg:"dbUser" json:"dbUser,omitempty"`
+	OSUser   string `pg:"osUser" json:"osUser,omitempty"`
+	Hostname string `pg:"hostname" json:"hostname,omitempty"`
+	Version  string `pg:"version" json:"version,omitempty"`
+	Revision string `pg:"revision" json:"revision,omitempty"`
+}
+
+// Duration returns duration of attempt.
+func (a PgAttempt) Duration() time.Duration {
+	return time.Duration(a.DurationMs) * time.Millisecond
+}
+
+// HistoryTable returns name of history table for migrations table.
+func HistoryTable(table string) string {
+	return table + "History"
+}
+
+// newAttempt returns attempt of migration which was started at start and finished with err.
+func newAttempt(ctx context.Context, mg Migration, start time.Time, err error) PgAttempt {
+	a := PgAttempt{
+		Filename:      mg.Filename,
+		Status:        AttemptDone,
+		StartedAt:     start,
+		DurationMs:    time.Since(start).Milliseconds(),
+		Transactional: mg.Transactional,
+		Md5sum:        mg.Md5Sum,
+	}
+	if err == nil {
+		return a
+	}
+
+	a.Status, a.Error = AttemptFailed, err.Error()
+	if ctx.Err() != nil {
+		a.Status = AttemptInterrupted
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		a.SQLState, a.Error, a.Detail, a.Hint = pgErr.Field('C'), pgErr.Field('M'), pgErr.Field('D'), pgErr.Field('H')
+		a.Position, _ = strconv.Atoi(pgErr.Field('P'))
+	}
+
+	var stmtErr *StatementError
+	if errors.As(err, &stmtErr) {
+		a.Statement = stmtErr.Index
+	}
+
+	return a
+}
+
+// withHistory returns apply func which saves each attempt to history table.
+func (m *Migrator) withHistory(apply func(context.Context, Migration) error) func(context.Context, Migration) error {
+	return func(ctx context.Context, mg Migration) error {
+		start := time.Now()
+		err := apply(ctx, mg)
+		m.saveAttempt(ctx, newAttempt(ctx, mg, start, err))
+
+		return err
+	}
+}
+
+// saveAttempt inserts attempt to history table by migrator connection, pool may have no free connections while
+// migrator lock is held (e.g. PoolSize = 1). Error is only logged, so it does not change result of migration.
+func (m *Migrator) saveAttempt(ctx context.Context, a PgAttempt) {
+	ctx = context.WithoutCancel(ctx)
+	a.OSUser, a.Hostname, a.Version, a.Revision = m.audit.osUser, m.audit.hostname, m.audit.version, m.audit.revision
+
+	_, err := m.db.ModelContext(ctx, &a).Insert()
+	if err != nil && m.pool != nil {
+		// go-pg closes connection after canceled statement (SIGINT or statement_timeout), so dedicated connection is used
+		err = m.withDedicatedConn(func(db *pg.DB) error {
+			_, err := db.ModelContext(ctx, &a).Insert()
+			return err
+		})
+	}
+
+	if err != nil {
+		log.Printf("warning: %s: save attempt to history failed: %v", a.Filename, err)
+	}
+}
+
+// withDedicatedConn runs fn on a new single connection pool with migrator options, it is closed after fn.
+func (m *Migrator) withDedicatedConn(fn func(db *pg.DB) error) error {
+	opt := *m.pool.Options()
+	opt.PoolSize = 1
+
+	db := pg.Connect(&opt)
+	defer db.Close()
+
+	return fn(withTableParams(db, m.cfg.Table))
+}
+
+// History returns all attempts to apply migration from history table in chronological order.
+func (m *Migrator) History(ctx context.Context, filename string) ([]PgAttempt, error) {
+	// check history table, read-only methods never create it
+	if ok, err := m.historyExists(ctx); err != nil || !ok {
+		return nil, err
+	}
+
+	var aa []PgAttempt
+	if err := m.selectModel(ctx, &aa).Where(`"filename" = ?`, filename).Order(`id`).Select(); err != nil {
+		return nil, fmt.Errorf(`fetch history of "%s" failed: %w`, filename, err)
+	}
+
+	return aa, nil
+}
+
+// LastAttempts returns last num attempts of all migrations from history table.
+func (m *Migrator) LastAttempts(ctx context.Context, num int) ([]PgAttempt, error) {
+	if ok, err := m.historyExists(ctx); err != nil || !ok {
+		return nil, err
+	}
+
+	var aa []PgAttempt
+	if err := m.selectModel(ctx, &aa).Order(`id DESC`).Limit(num).Select(); err != nil {
+		return nil, fmt.Errorf(`fetch last %d attempts failed: %w`, num, err)
+	}
+
+	return aa, nil
+}
+
+// historyExists checks if history table exists using catalog.
+func (m *Migrator) historyExists(ctx context.Context) (bool, error) {
+	table := string(m.db.Formatter().FormatQuery(nil, "?", pg.Ident(HistoryTable(m.cfg.Table))))
+
+	var exists bool
+	if _, err := m.db.QueryOneContext(ctx, pg.Scan(&exists), `select to_regclass(?) is not null`, table); err != nil {
+		return false, fmt.Errorf("check history table failed: %w", err)
+	}
+
+	return exists, nil
+}
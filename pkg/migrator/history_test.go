@@ -0,0 +1,56 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPgFieldsError is pg.Error with all fields.
+type testPgFieldsError map[byte]string
+
+func (e testPgFieldsError) Error() string            { return "ERROR #" + e['C'] + " " + e['M'] }
+func (e testPgFieldsError) Field(f byte) string      { return e[f] }
+func (e testPgFieldsError) IntegrityViolation() bool { return false }
+
+func TestNewAttempt(t *testing.T) {
+	ctx := context.Background()
+	mg := Migration{Filename: "2022-12-12-01-create-table-statuses.sql", Md5Sum: "abc", Transactional: true}
+	start := time.Now().Add(-time.Second)
+
+	a := newAttempt(ctx, mg, start, nil)
+	assert.Equal(t, AttemptDone, a.Status)
+	assert.Equal(t, mg.Filename, a.Filename)
+	assert.Equal(t, "abc", a.Md5sum)
+	assert.True(t, a.Transactional)
+	assert.GreaterOrEqual(t, a.DurationMs, int64(1000))
+	assert.Empty(t, a.Error)
+
+	pgErr := testPgFieldsError{'C': "42P01", 'M': `relation "news" does not exist`, 'D': "detail", 'H': "hint", 'P': "15"}
+	a = newAttempt(ctx, mg, start, fmt.Errorf("apply migration failed: %w", pgErr))
+	assert.Equal(t, AttemptFailed, a.Status)
+	assert.Equal(t, "42P01", a.SQLState)
+	assert.Equal(t, `relation "news" does not exist`, a.Error)
+	assert.Equal(t, "detail", a.Detail)
+	assert.Equal(t, "hint", a.Hint)
+	assert.Equal(t, 15, a.Position)
+	assert.Zero(t, a.Statement)
+
+	a = newAttempt(ctx, mg, start, &StatementError{Index: 2, Total: 3, Statement: "select 1/0", Err: testPgFieldsError{'C': "22012", 'M': "division by zero"}})
+	assert.Equal(t, AttemptFailed, a.Status)
+	assert.Equal(t, "22012", a.SQLState)
+	assert.Equal(t, 2, a.Statement)
+
+	a = newAttempt(ctx, mg, start, errors.New("read file failed"))
+	assert.Equal(t, "read file failed", a.Error)
+	assert.Empty(t, a.SQLState)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	a = newAttempt(canceled, mg, start, context.Canceled)
+	assert.Equal(t, AttemptInterrupted, a.Status)
+}
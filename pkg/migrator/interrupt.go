@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+)
+
+// InterruptedError is returned when migrations were interrupted by context cancellation, e.g. on SIGINT.
+// Running statement is canceled on the server by go-pg, transactional migration is rolled back,
+// non-transactional migration is left unfinished in migrations table, see Resolve.
+type InterruptedError struct {
+	Filename      string // interrupted migration, empty if migrations were interrupted between files
+	Transactional bool
+	Err           error
+}
+
+func (e *InterruptedError) Error() string {
+	switch {
+	case e.Filename == "":
+		return fmt.Sprintf("interrupted: %v", e.Err)
+	case e.Transactional:
+		return fmt.Sprintf(`interrupted, migration "%s" was rolled back: %v`, e.Filename, e.Err)
+	}
+
+	return fmt.Sprintf(`interrupted, migration "%s" is unfinished: %v`, e.Filename, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Unfinished reports whether interrupted migration was left unfinished in migrations table.
+func (e *InterruptedError) Unfinished() bool {
+	return e.Filename != "" && !e.Transactional
+}
+
+// checkInterrupted returns InterruptedError if ctx was canceled before next migration.
+func checkInterrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &InterruptedError{Err: err}
+	}
+
+	return nil
+}
+
+// interrupted wraps err of migration into InterruptedError if ctx was canceled.
+func interrupted(ctx context.Context, mg Migration, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	return &InterruptedError{Filename: mg.Filename, Transactional: mg.Transactional, Err: err}
+}
@@ -0,0 +1,39 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterrupted(t *testing.T) {
+	errFailed := errors.New("canceling statement due to user request")
+	tr := Migration{Filename: "2022-12-12-01-create-table-statuses.sql", Transactional: true}
+	nontr := Migration{Filename: "2022-12-12-02-create-index-NONTR.sql"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, checkInterrupted(ctx))
+	require.Equal(t, errFailed, interrupted(ctx, tr, errFailed))
+	require.NoError(t, interrupted(ctx, tr, nil))
+
+	cancel()
+	var ie *InterruptedError
+	require.ErrorAs(t, checkInterrupted(ctx), &ie)
+	assert.Equal(t, "interrupted: context canceled", ie.Error())
+	assert.ErrorIs(t, ie, context.Canceled)
+	assert.False(t, ie.Unfinished())
+
+	require.NoError(t, interrupted(ctx, tr, nil))
+
+	require.ErrorAs(t, interrupted(ctx, tr, errFailed), &ie)
+	assert.Equal(t, `interrupted, migration "2022-12-12-01-create-table-statuses.sql" was rolled back: canceling statement due to user request`, ie.Error())
+	assert.ErrorIs(t, ie, errFailed)
+	assert.False(t, ie.Unfinished())
+
+	require.ErrorAs(t, interrupted(ctx, nontr, errFailed), &ie)
+	assert.Equal(t, `interrupted, migration "2022-12-12-02-create-index-NONTR.sql" is unfinished: canceling statement due to user request`, ie.Error())
+	assert.True(t, ie.Unfinished())
+}
@@ -0,0 +1,116 @@
+package migrator
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// IgnoreReason is a reason why sql file in migrations directory is not applied.
+type IgnoreReason string
+
+const (
+	IgnoreReasonFileMask     IgnoreReason = "does not match FileMask"
+	IgnoreReasonManual       IgnoreReason = "manual migration"
+	IgnoreReasonSubdirectory IgnoreReason = "in subdirectory"
+)
+
+// IgnoredFile is a sql file in migrations directory which is never applied.
+type IgnoredFile struct {
+	Filename string       `json:"filename"`
+	Reason   IgnoreReason `json:"reason"`
+}
+
+// Intended reports whether file is ignored on purpose: MANUAL migrations are never applied by design.
+func (f IgnoredFile) Intended() bool {
+	return f.Reason == IgnoreReasonManual
+}
+
+// IgnoredFiles returns sql files from migrations directory and its subdirectories which are skipped by readAllFiles.
+// Undo files and files from repeatable dir are not returned.
+func (m *Migrator) IgnoredFiles() ([]IgnoredFile, error) {
+	files, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read files failed: %w", err)
+	}
+
+	var res []IgnoredFile
+	for _, f := range files {
+		if f.IsDir() {
+			if m.isRepeatableDir(f.Name()) {
+				continue
+			}
+
+			sub, err := m.subdirectorySQLFiles(f.Name())
+			if err != nil {
+				return nil, err
+			}
+
+			for _, filename := range sub {
+				res = append(res, IgnoredFile{Filename: filename, Reason: IgnoreReasonSubdirectory})
+			}
+			continue
+		}
+
+		switch name := f.Name(); {
+		case !strings.HasSuffix(name, ".sql") || isDownFile(name):
+			continue
+		case strings.HasSuffix(name, "MANUAL.sql"):
+			res = append(res, IgnoredFile{Filename: name, Reason: IgnoreReasonManual})
+		case !m.fileMask.MatchString(name):
+			res = append(res, IgnoredFile{Filename: name, Reason: IgnoreReasonFileMask})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Filename < res[j].Filename })
+
+	return res, nil
+}
+
+// subdirectorySQLFiles returns sql files from subdirectory of migrations directory recursively.
+func (m *Migrator) subdirectorySQLFiles(dir string) ([]string, error) {
+	var filenames []string
+	err := fs.WalkDir(m.fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() && m.isRepeatableDir(p) {
+			// nested repeatable dir, e.g. sql/repeatable
+			return fs.SkipDir
+		} else if d.IsDir() || !strings.HasSuffix(d.Name(), ".sql") || isDownFile(d.Name()) {
+			return nil
+		}
+
+		filenames = append(filenames, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read subdirectory %s failed: %w", dir, err)
+	}
+
+	return filenames, nil
+}
+
+// isRepeatableDir reports whether dir of migrations directory is repeatable dir, paths are compared cleaned.
+func (m *Migrator) isRepeatableDir(dir string) bool {
+	return m.cfg.RepeatableDir != "" && path.Clean(dir)+"/" == m.repeatablePrefix()
+}
+
+// Lint returns sql files from migrations directory which would never be applied by mistake:
+// files which do not match FileMask and files in subdirectories. MANUAL migrations are skipped.
+func (m *Migrator) Lint() ([]IgnoredFile, error) {
+	ff, err := m.IgnoredFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []IgnoredFile
+	for _, f := range ff {
+		if !f.Intended() {
+			res = append(res, f)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package migrator
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_IgnoredFiles(t *testing.T) {
+	t.Run("without repeatable dir", func(t *testing.T) {
+		m := NewMigrator(nil, NewDefaultConfig(), "testdata")
+		got, err := m.IgnoredFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []IgnoredFile{
+			{Filename: "2022-12-13-03-add-comments-tags-MANUAL.sql", Reason: IgnoreReasonManual},
+			{Filename: "2023-01-01.sql", Reason: IgnoreReasonFileMask},
+			{Filename: "repeatable/v-published-news.sql", Reason: IgnoreReasonSubdirectory},
+			{Filename: "v1.sql", Reason: IgnoreReasonFileMask},
+		}, got)
+	})
+
+	t.Run("with repeatable dir", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.RepeatableDir = "repeatable"
+		m := NewMigrator(nil, cfg, "testdata")
+		got, err := m.IgnoredFiles()
+		require.NoError(t, err)
+		assert.Len(t, got, 3)
+	})
+
+	fsys := fstest.MapFS{
+		"2022-12-12-01-create-table.sql":      {Data: []byte("select 1;")},
+		"sql/repeatable/v-news.sql":           {Data: []byte("select 1;")},
+		"sql/2022-12-12-02-create-index.sql":  {Data: []byte("select 1;")},
+		"repeatable/2022-12-12-03-create.sql": {Data: []byte("select 1;")},
+	}
+	for _, dir := range []string{"sql/repeatable", "./sql/repeatable/", "sql//repeatable"} {
+		t.Run("nested repeatable dir "+dir, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.RepeatableDir = dir
+			m := NewMigratorFS(nil, cfg, fsys)
+			got, err := m.IgnoredFiles()
+			require.NoError(t, err)
+			assert.Equal(t, []IgnoredFile{
+				{Filename: "repeatable/2022-12-12-03-create.sql", Reason: IgnoreReasonSubdirectory},
+				{Filename: "sql/2022-12-12-02-create-index.sql", Reason: IgnoreReasonSubdirectory},
+			}, got)
+		})
+	}
+
+	t.Run("unclean repeatable dir", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.RepeatableDir = "./repeatable/"
+		m := NewMigratorFS(nil, cfg, fsys)
+		got, err := m.IgnoredFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []IgnoredFile{
+			{Filename: "sql/2022-12-12-02-create-index.sql", Reason: IgnoreReasonSubdirectory},
+			{Filename: "sql/repeatable/v-news.sql", Reason: IgnoreReasonSubdirectory},
+		}, got)
+	})
+}
+
+func TestMigrator_Lint(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.RepeatableDir = "repeatable"
+	m := NewMigrator(nil, cfg, "testdata")
+
+	got, err := m.Lint()
+	require.NoError(t, err)
+	assert.Equal(t, []IgnoredFile{
+		{Filename: "2023-01-01.sql", Reason: IgnoreReasonFileMask},
+		{Filename: "v1.sql", Reason: IgnoreReasonFileMask},
+	}, got)
+}
@@ -0,0 +1,148 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+const (
+	// lockClassID is the first key of pg_advisory_lock(int4, int4), "pgmg" in ASCII.
+	lockClassID = 0x70676d67
+
+	// lockRetryInterval is a pause between pg_try_advisory_lock attempts.
+	lockRetryInterval = 500 * time.Millisecond
+)
+
+// LockHolder is a backend which holds migrator advisory lock.
+type LockHolder struct {
+	PID             int       `pg:"pid"`
+	ApplicationName string    `pg:"application_name"`
+	ClientAddr      string    `pg:"client_addr"`
+	BackendStart    time.Time `pg:"backend_start"`
+}
+
+func (h LockHolder) String() string {
+	return fmt.Sprintf(`pid=%d application_name="%s" client_addr=%s backend_start=%s`,
+		h.PID, h.ApplicationName, h.ClientAddr, h.BackendStart.Format(time.DateTime))
+}
+
+// LockError is returned when migrator advisory lock was not acquired within AdvisoryLockTimeout.
+type LockError struct {
+	Table   string
+	Timeout time.Duration
+	Holders []LockHolder
+}
+
+func (e *LockError) Error() string {
+	holders := "unknown"
+	if len(e.Holders) > 0 {
+		hh := make([]string, 0, len(e.Holders))
+		for _, h := range e.Holders {
+			hh = append(hh, h.String())
+		}
+		holders = strings.Join(hh, "; ")
+	}
+
+	return fmt.Sprintf(`migrations table "%s" is locked by another process (waited %v), lock holder: %s`, e.Table, e.Timeout, holders)
+}
+
+// lockKey returns the second key of pg_advisory_lock(int4, int4) for migrations table.
+func (m *Migrator) lockKey() int32 {
+	return int32(crc32.ChecksumIEEE([]byte(m.cfg.Table)))
+}
+
+// withLock runs fn on a single connection which holds migrator advisory lock.
+// Migrator passed to fn must be used for all queries inside fn.
+func (m *Migrator) withLock(ctx context.Context, fn func(lm *Migrator) error) error {
+	if m.pool == nil {
+		return fn(m)
+	}
+
+	conn := m.pool.Conn()
+	defer conn.Close()
+
+	if err := m.acquireLock(ctx, conn); err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `select pg_advisory_unlock(?, ?)`, lockClassID, m.lockKey())
+	}()
+
+	lm := *m
+	lm.db = conn
+
+	// notices of migrations are received from locked connection only
+	lm.backend.addr = m.pool.Options().Addr
+	if _, err := conn.QueryOneContext(ctx, pg.Scan(&lm.backend.pid), `select pg_backend_pid()`); err != nil {
+		return fmt.Errorf("fetch backend pid failed: %w", err)
+	}
+
+	return fn(&lm)
+}
+
+// acquireLock tries to take migrator advisory lock until AdvisoryLockTimeout is reached.
+func (m *Migrator) acquireLock(ctx context.Context, db orm.DB) error {
+	var timeout time.Duration
+	if m.cfg.AdvisoryLockTimeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(m.cfg.AdvisoryLockTimeout); err != nil {
+			return fmt.Errorf("invalid AdvisoryLockTimeout: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var ok bool
+		if _, err := db.QueryOneContext(ctx, pg.Scan(&ok), `select pg_try_advisory_lock(?, ?)`, lockClassID, m.lockKey()); err != nil {
+			return fmt.Errorf("acquire lock failed: %w", err)
+		} else if ok {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	holders, err := m.lockHolders(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return &LockError{Table: m.cfg.Table, Timeout: timeout, Holders: holders}
+}
+
+// lockHolders returns backends which hold migrator advisory lock in current database.
+func (m *Migrator) lockHolders(ctx context.Context, db orm.DB) ([]LockHolder, error) {
+	var holders []LockHolder
+	_, err := db.QueryContext(ctx, &holders, `
+		select a.pid, coalesce(a.application_name, '') as application_name,
+			coalesce(host(a.client_addr), 'local') as client_addr, a.backend_start
+		from pg_locks l
+			join pg_stat_activity a on a.pid = l.pid
+		where l.locktype = 'advisory'
+			and l.granted
+			and l.database = (select oid from pg_database where datname = current_database())
+			and l.classid = ?::int4::oid
+			and l.objid = ?::int4::oid
+			and l.objsubid = 2
+	`, lockClassID, m.lockKey())
+	if err != nil {
+		return nil, fmt.Errorf("fetch lock holders failed: %w", err)
+	}
+
+	return holders, nil
+}
@@ -4,43 +4,77 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
 )
 
+// pgDB is a common interface for *pg.DB and *pg.Conn.
+type pgDB interface {
+	orm.DB
+	Begin() (*pg.Tx, error)
+}
+
 type Migrator struct {
-	db       *pg.DB
+	db       pgDB
+	pool     *pg.DB // used for dedicated connections, see withLock
 	cfg      Config
-	rootDir  string // patches
+	rootDir  string // patches, empty if migrator was created by NewMigratorFS
+	fsys     fs.FS  // migration files
 	fileMask *regexp.Regexp
+	audit    auditInfo
+
+	backend       noticeBackend // backend of locked connection, notices are received from it, see withLock
+	noticeWarning *sync.Once    // logs once that notices are not captured, see NoticeOptions
 }
 
+// NewMigrator returns migrator which reads migration files from rootDir.
 func NewMigrator(db *pg.DB, cfg Config, rootDir string) *Migrator {
+	m := NewMigratorFS(db, cfg, os.DirFS(rootDir))
+	m.rootDir = rootDir
+
+	return m
+}
+
+// NewMigratorFS returns migrator which reads migration files from fsys, e.g. embed.FS.
+// Migration files are read from the root of fsys, use fs.Sub for subdirectory.
+// PostgreSQL notices are captured if db was created with NoticeOptions.
+func NewMigratorFS(db *pg.DB, cfg Config, fsys fs.FS) *Migrator {
 	m := &Migrator{
-		db:       db,
-		cfg:      cfg,
-		rootDir:  rootDir,
-		fileMask: regexp.MustCompile(cfg.FileMask),
+		cfg:           cfg,
+		fsys:          fsys,
+		fileMask:      regexp.MustCompile(cfg.FileMask),
+		audit:         newAuditInfo(cfg),
+		noticeWarning: &sync.Once{},
 	}
 
 	if db != nil {
-		m.db = db.WithParam("migrationTable", pg.Ident(cfg.Table))
+		m.pool = withTableParams(db, cfg.Table)
+		m.db = m.pool
 	}
 
 	return m
 }
 
+// withTableParams returns db with migrations, history and schema table names for models and queries,
+// see PgMigration, PgAttempt and SchemaTable.
+func withTableParams(db *pg.DB, table string) *pg.DB {
+	return db.WithParam("migrationTable", pg.Ident(table)).
+		WithParam("historyTable", pg.Ident(HistoryTable(table))).
+		WithParam("schemaTable", pg.Ident(SchemaTable(table)))
+}
+
 // writeMigrationToDB inserts log that migration was completed in postgres
-func writeMigrationToDB(ctx context.Context, mg Migration, tx *pg.Tx, start time.Time) error {
+func (m *Migrator) writeMigrationToDB(ctx context.Context, mg Migration, tx *pg.Tx, start time.Time) error {
 	finish := time.Now()
-	pm := mg.ToDB()
+	pm := m.toDB(mg)
 	pm.StartedAt = start
 	pm.FinishedAt = &finish
 
@@ -52,13 +86,7 @@ func writeMigrationToDB(ctx context.Context, mg Migration, tx *pg.Tx, start time
 
 // readAllFiles read files from migrator root dir and return its filenames
 func (m *Migrator) readAllFiles() ([]string, error) {
-	dir, err := os.Open(m.rootDir)
-	if err != nil {
-		return nil, fmt.Errorf("open dir failed: %w", err)
-	}
-	defer dir.Close()
-
-	files, err := dir.ReadDir(-1)
+	files, err := fs.ReadDir(m.fsys, ".")
 	if err != nil {
 		return nil, fmt.Errorf("read files failed: %w", err)
 	}
@@ -70,6 +98,9 @@ func (m *Migrator) readAllFiles() ([]string, error) {
 		} else if strings.HasSuffix(f.Name(), "MANUAL.sql") {
 			// skip manual migrations
 			continue
+		} else if isDownFile(f.Name()) {
+			// skip undo files, see Rollback
+			continue
 		}
 
 		filenames = append(filenames, f.Name())
@@ -97,12 +128,26 @@ func (m *Migrator) removeCompleted(all, completed []string) (res []string) {
 }
 
 // Plan reads filenames from migrator root dir, fetch completed filenames from db, compare its and returns .
+// It returns UnfinishedError if unfinished non-transactional migrations were found.
+// If migration table does not exist, all filenames are returned.
 func (m *Migrator) Plan(ctx context.Context) ([]string, error) {
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
 		return nil, err
+	} else if !ok {
+		return m.readAllFiles()
 	}
 
+	// check failed non-transactional migrations
+	if err := m.checkUnfinished(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.plan(ctx)
+}
+
+// plan returns migration files which are not applied.
+func (m *Migrator) plan(ctx context.Context) ([]string, error) {
 	// read all files
 	filenames, err := m.readAllFiles()
 	if err != nil {
@@ -111,26 +156,61 @@ func (m *Migrator) Plan(ctx context.Context) ([]string, error) {
 		return nil, nil
 	}
 
+	// compare and plan
+	return m.removeApplied(ctx, filenames)
+}
+
+// removeApplied removes filenames that are already in migrations table.
+func (m *Migrator) removeApplied(ctx context.Context, filenames []string) ([]string, error) {
+	if len(filenames) == 0 {
+		return nil, nil
+	}
+
 	// fetch completed migrations from db
 	var completed []string
-	_, err = m.db.QueryContext(ctx, &completed, `select "filename" from ? where "filename" in (?)`, pg.Ident(m.cfg.Table), pg.In(filenames))
+	_, err := m.db.QueryContext(ctx, &completed, `select "filename" from ? where "filename" in (?)`, pg.Ident(m.cfg.Table), pg.In(filenames))
 	if err != nil {
 		return nil, err
 	}
 
-	// compare and plan
 	return m.removeCompleted(filenames, completed), nil
 }
 
-// Run run migrations from files, apply transactional and non transactional
-func (m *Migrator) Run(ctx context.Context, filenames []string, chCurrentFile chan string) error {
-	defer close(chCurrentFile)
+// Run run migrations from files, apply transactional and non transactional.
+// It holds migrator advisory lock and skips filenames already applied by another process.
+// Out-of-order migrations are checked by OutOfOrder policy before applying.
+// Repeatable migrations are applied after all versioned migrations.
+func (m *Migrator) Run(ctx context.Context, filenames []string, obs Observer) error {
+	return m.withLock(ctx, func(lm *Migrator) error {
+		// create migration table if not exists
+		if err := lm.createMigratorTable(ctx); err != nil {
+			return err
+		}
 
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
-		return err
-	}
+		// check failed non-transactional migrations
+		if err := lm.checkUnfinished(ctx); err != nil {
+			return err
+		}
+
+		pending, err := lm.removeApplied(ctx, filenames)
+		if err != nil {
+			return err
+		}
+
+		if err = lm.checkOutOfOrder(ctx, pending); err != nil {
+			return err
+		}
+
+		if err = lm.run(ctx, pending, obs); err != nil {
+			return err
+		}
+
+		return lm.runRepeatable(ctx, obs)
+	})
+}
 
+// run applies migrations from files without locking.
+func (m *Migrator) run(ctx context.Context, filenames []string, obs Observer) error {
 	// prepare migrations
 	mm, err := m.newMigrations(filenames)
 	if err != nil {
@@ -138,29 +218,44 @@ func (m *Migrator) Run(ctx context.Context, filenames []string, chCurrentFile ch
 	}
 
 	// apply migrations
+	var notices []string
 	for _, mg := range mm {
-		chCurrentFile <- mg.Filename
-		if mg.Transactional {
-			err = m.applyMigration(ctx, mg)
-		} else {
-			err = m.applyNonTransactionalMigration(ctx, mg)
+		if err = checkInterrupted(ctx); err != nil {
+			return err
 		}
 
+		notices, err = m.trackWithNotices(obs, mg.Filename, func() error {
+			if mg.Transactional {
+				return interrupted(ctx, mg, m.retryOnLockTimeout(ctx, mg, m.withHistory(m.applyMigration)))
+			}
+			return interrupted(ctx, mg, m.withHistory(func(ctx context.Context, mg Migration) error {
+				return m.applyNonTransactionalMigration(ctx, mg, obs)
+			})(ctx, mg))
+		})
 		if err != nil {
 			return fmt.Errorf("%s: %w", mg.Filename, err)
 		}
+
+		if err = m.saveNotices(ctx, mg.Filename, notices); err != nil {
+			return fmt.Errorf("%s: %w", mg.Filename, err)
+		}
 	}
 
-	return err
+	return nil
+}
+
+// ReadMigrations reads migration files by filenames, e.g. returned by Plan.
+func (m *Migrator) ReadMigrations(filenames []string) (Migrations, error) {
+	return m.newMigrations(filenames)
 }
 
 // newMigrations create Migrations from filenames
 func (m *Migrator) newMigrations(filenames []string) (Migrations, error) {
 	var mm Migrations
 	for _, filename := range filenames {
-		mg, err := NewMigration(m.rootDir, filename)
+		mg, err := NewMigrationFS(m.fsys, filename)
 		if err != nil {
-			return nil, fmt.Errorf("%s open failed: %w", mg.Filename, err)
+			return nil, fmt.Errorf("%s: %w", mg.Filename, err)
 		}
 
 		mm = append(mm, mg)
@@ -196,7 +291,7 @@ func (m *Migrator) applyMigration(ctx context.Context, mg Migration) (err error)
 		err = finishTxOnErr(tx, err)
 	}()
 
-	if err = m.setStatementTimeout(ctx, tx); err != nil {
+	if err = m.setTimeouts(ctx, tx, mg); err != nil {
 		return err
 	}
 
@@ -206,43 +301,111 @@ func (m *Migrator) applyMigration(ctx context.Context, mg Migration) (err error)
 		return fmt.Errorf(`apply migration failed: %w`, err)
 	}
 
-	return writeMigrationToDB(ctx, mg, tx, start)
+	return m.writeMigrationToDB(ctx, mg, tx, start)
 }
 
-// setStatementTimeout set statement timeout to transaction connection
-func (m *Migrator) setStatementTimeout(ctx context.Context, tx orm.DB) error {
-	if m.cfg.StatementTimeout == "" {
-		return nil
+// setTimeouts set statement timeout and lock timeout for migration to transaction.
+// Migration directives override StatementTimeout and LockTimeout from config.
+func (m *Migrator) setTimeouts(ctx context.Context, tx *pg.Tx, mg Migration) error {
+	return m.setTimeoutsWith(ctx, tx, mg, `set local`)
+}
+
+// setSessionTimeouts set statement timeout and lock timeout for migration to connection.
+// They must be reset by resetSessionTimeouts.
+func (m *Migrator) setSessionTimeouts(ctx context.Context, mg Migration) error {
+	return m.setTimeoutsWith(ctx, m.db, mg, `set`)
+}
+
+func (m *Migrator) setTimeoutsWith(ctx context.Context, db orm.DB, mg Migration, set string) error {
+	statementTimeout := m.cfg.StatementTimeout
+	if mg.StatementTimeout != "" {
+		statementTimeout = mg.StatementTimeout
 	}
 
-	if _, err := tx.ExecContext(ctx, `set statement_timeout to ?`, m.cfg.StatementTimeout); err != nil {
-		return fmt.Errorf(`set statement timeout failed: %w`, err)
+	if statementTimeout != "" {
+		if _, err := db.ExecContext(ctx, set+` statement_timeout to ?`, pgDuration(statementTimeout)); err != nil {
+			return fmt.Errorf(`set statement timeout failed: %w`, err)
+		}
+	}
+
+	lockTimeout := m.cfg.LockTimeout
+	if mg.LockTimeout != "" {
+		lockTimeout = mg.LockTimeout
+	}
+
+	if lockTimeout != "" {
+		if _, err := db.ExecContext(ctx, set+` lock_timeout to ?`, pgDuration(lockTimeout)); err != nil {
+			return fmt.Errorf(`set lock timeout failed: %w`, err)
+		}
 	}
 
 	return nil
 }
 
-// applyNonTransactionalMigration apply non-transactional migration
-func (m *Migrator) applyNonTransactionalMigration(ctx context.Context, mg Migration) error {
-	if err := m.setStatementTimeout(ctx, m.db); err != nil {
+// resetSessionTimeouts resets statement timeout and lock timeout of connection to defaults.
+func (m *Migrator) resetSessionTimeouts(ctx context.Context) {
+	_, _ = m.db.ExecContext(context.WithoutCancel(ctx), `reset statement_timeout; reset lock_timeout`)
+}
+
+// StatementError is returned when a statement of non-transactional migration fails.
+type StatementError struct {
+	Index     int // starts from 1
+	Total     int
+	Statement string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	stmt := strings.Join(strings.Fields(e.Statement), " ")
+	if len(stmt) > 80 {
+		stmt = stmt[:80] + "..."
+	}
+
+	msg := fmt.Sprintf("statement %d of %d failed: %v\n\t%s", e.Index, e.Total, e.Err, stmt)
+	if e.Index > 1 {
+		msg += fmt.Sprintf("\nstatements 1-%d were applied, check them manually", e.Index-1)
+	}
+
+	return msg
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// applyNonTransactionalMigration apply non-transactional migration.
+// Migration is split into statements which are executed one by one, because multi-statement
+// query is executed in implicit transaction (e.g. create index concurrently fails).
+func (m *Migrator) applyNonTransactionalMigration(ctx context.Context, mg Migration, obs Observer) error {
+	if err := m.setSessionTimeouts(ctx, mg); err != nil {
 		return err
 	}
+	defer m.resetSessionTimeouts(ctx)
+
 	// insert into pgMigrations
-	pm := mg.ToDB()
+	pm := m.toDB(mg)
 	pm.StartedAt = time.Now()
 	if _, err := m.db.ModelContext(ctx, pm).Insert(); err != nil {
 		return fmt.Errorf(`add new migration failed: %w`, err)
 	}
 
 	// run
-	if _, err := m.db.ExecContext(ctx, string(mg.Data)); err != nil {
-		return fmt.Errorf(`apply migration failed: %w`, err)
+	stmts := splitStatements(string(mg.Data))
+	for i, stmt := range stmts {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf(`apply migration failed: %w`, &StatementError{Index: i + 1, Total: len(stmts), Statement: stmt, Err: err})
+		}
+
+		notify(obs, Event{Type: EventStatement, Filename: mg.Filename, Statement: i + 1, Total: len(stmts)})
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf(`apply migration failed: %w`, &StatementError{Index: i + 1, Total: len(stmts), Statement: stmt, Err: err})
+		}
 	}
 
-	// update pgMigrations
+	// update pgMigrations, all statements were applied, so it is done even if ctx was canceled
 	now := time.Now()
 	pm.FinishedAt = &now
-	if _, err := m.db.ModelContext(ctx, pm).Column("finishedAt").WherePK().Update(); err != nil {
+	if _, err := m.db.ModelContext(context.WithoutCancel(ctx), pm).Column("finishedAt").WherePK().Update(); err != nil {
 		return fmt.Errorf(`update finishedAt migration failed: %w`, err)
 	}
 
@@ -251,33 +414,38 @@ func (m *Migrator) applyNonTransactionalMigration(ctx context.Context, mg Migrat
 
 // DryRun tries to apply migrations. Runs migrations inside single transaction and always rolls back it
 // returns err, if apply done with error or if non-transactional migration was found
-func (m *Migrator) DryRun(ctx context.Context, filenames []string, chCurrentFile chan string) error {
-	defer close(chCurrentFile)
+func (m *Migrator) DryRun(ctx context.Context, filenames []string, obs Observer) error {
+	return m.withLock(ctx, func(lm *Migrator) error {
+		// create migration table if not exists
+		if err := lm.createMigratorTable(ctx); err != nil {
+			return err
+		}
 
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
-		return err
-	}
+		pending, err := lm.removeApplied(ctx, filenames)
+		if err != nil {
+			return err
+		}
 
-	// prepare migrations
-	mm, err := m.newMigrations(filenames)
-	if err != nil {
-		return fmt.Errorf("prepare migrations failed: %w", err)
-	} else if t, ok := mm.FirstNonTransactional(); ok {
-		// check NONTR
-		return fmt.Errorf(`non transactional migration found "%s", run all migrations before it, please`, t.Filename)
-	}
+		// prepare migrations
+		mm, err := lm.newMigrations(pending)
+		if err != nil {
+			return fmt.Errorf("prepare migrations failed: %w", err)
+		} else if t, ok := mm.FirstNonTransactional(); ok {
+			// check NONTR
+			return fmt.Errorf(`non transactional migration found "%s", run all migrations before it, please`, t.Filename)
+		}
 
-	// dryRun migrations
-	if err = m.dryRunMigrations(ctx, mm, chCurrentFile); err != nil {
-		return fmt.Errorf("dry run migrations failed: %w", err)
-	}
+		// dryRun migrations
+		if err = lm.dryRunMigrations(ctx, mm, obs); err != nil {
+			return fmt.Errorf("dry run migrations failed: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // dryRunMigrations runs and rolls back migrations
-func (m *Migrator) dryRunMigrations(ctx context.Context, mm Migrations, chCurrentFile chan string) (err error) {
+func (m *Migrator) dryRunMigrations(ctx context.Context, mm Migrations, obs Observer) (err error) {
 	var tx *pg.Tx
 	tx, err = m.db.Begin()
 	if err != nil {
@@ -290,15 +458,20 @@ func (m *Migrator) dryRunMigrations(ctx context.Context, mm Migrations, chCurren
 
 	// apply migrations
 	for _, mg := range mm {
-		chCurrentFile <- mg.Filename
-
-		// run
-		start := time.Now()
-		if _, err = tx.ExecContext(ctx, string(mg.Data)); err != nil {
-			return fmt.Errorf(`apply migration "%s" failed: %w`, mg.Filename, err)
+		if err = checkInterrupted(ctx); err != nil {
+			return err
 		}
 
-		if err = writeMigrationToDB(ctx, mg, tx, start); err != nil {
+		_, err = m.trackWithNotices(obs, mg.Filename, func() error {
+			// run
+			start := time.Now()
+			if _, err := tx.ExecContext(ctx, string(mg.Data)); err != nil {
+				return interrupted(ctx, mg, fmt.Errorf(`apply migration "%s" failed: %w`, mg.Filename, err))
+			}
+
+			return interrupted(ctx, mg, m.writeMigrationToDB(ctx, mg, tx, start))
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -307,28 +480,38 @@ func (m *Migrator) dryRunMigrations(ctx context.Context, mm Migrations, chCurren
 }
 
 // Skip marks migrations as completed
-func (m *Migrator) Skip(ctx context.Context, filenames []string, chCurrentFile chan string) error {
-	defer close(chCurrentFile)
+func (m *Migrator) Skip(ctx context.Context, filenames []string, obs Observer) error {
+	return m.withLock(ctx, func(lm *Migrator) error {
+		// create migration table if not exists
+		if err := lm.createMigratorTable(ctx); err != nil {
+			return err
+		}
 
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
-		return err
-	}
+		// check failed non-transactional migrations
+		if err := lm.checkUnfinished(ctx); err != nil {
+			return err
+		}
 
-	// prepare migrations
-	mm, err := m.newMigrations(filenames)
-	if err != nil {
-		return fmt.Errorf("prepare migrations failed: %w", err)
-	}
+		pending, err := lm.removeApplied(ctx, filenames)
+		if err != nil {
+			return err
+		}
 
-	// skip migrations
-	if err := m.skipMigrations(ctx, mm, chCurrentFile); err != nil {
-		return fmt.Errorf("skip migrations failed: %w", err)
-	}
-	return nil
+		// prepare migrations
+		mm, err := lm.newMigrations(pending)
+		if err != nil {
+			return fmt.Errorf("prepare migrations failed: %w", err)
+		}
+
+		// skip migrations
+		if err := lm.skipMigrations(ctx, mm, obs); err != nil {
+			return fmt.Errorf("skip migrations failed: %w", err)
+		}
+		return nil
+	})
 }
 
-func (m *Migrator) skipMigrations(ctx context.Context, mm Migrations, chCurrentFile chan string) (err error) {
+func (m *Migrator) skipMigrations(ctx context.Context, mm Migrations, obs Observer) (err error) {
 	var tx *pg.Tx
 	tx, err = m.db.Begin()
 	if err != nil {
@@ -341,10 +524,10 @@ func (m *Migrator) skipMigrations(ctx context.Context, mm Migrations, chCurrentF
 
 	// write migrations to pgMigrations table
 	for _, mg := range mm {
-		chCurrentFile <- mg.Filename
-		if err = writeMigrationToDB(ctx, mg, tx, time.Now()); err != nil {
+		if err = m.writeMigrationToDB(ctx, mg, tx, time.Now()); err != nil {
 			return err
 		}
+		notify(obs, Event{Type: EventSkipped, Filename: mg.Filename})
 	}
 
 	return nil
@@ -358,30 +541,46 @@ func alwaysRollbackTx(tx *pg.Tx, err error) error {
 	return err
 }
 
-// Last shows applied migrations
+// Last shows applied migrations, repeatable migrations are excluded
 func (m *Migrator) Last(ctx context.Context, num int) ([]PgMigration, error) {
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
 		return nil, err
+	} else if !ok {
+		return nil, nil
 	}
 
 	// fetch last migrations
 	var pm []PgMigration
-	if err := m.db.ModelContext(ctx, &pm).Order(`id DESC`).Limit(num).Select(); err != nil {
+	if err := m.whereVersioned(m.selectModel(ctx, &pm)).Order(`id DESC`).Limit(num).Select(); err != nil {
 		return nil, fmt.Errorf(`fetch last %d migrations failed: %w`, num, err)
 	}
 
 	return pm, nil
 }
 
+// VerifyResult is a result of Verify.
+type VerifyResult struct {
+	Invalid []PgMigration      `json:"invalid"` // md5sum of local file differs
+	Missing []PgMigration      `json:"missing"` // local file was not found
+	Renamed []RenamedMigration `json:"renamed"` // local file was not found, but other file has the same md5sum
+}
+
 // Verify compare md5 sum applied migrations with migrations in filesystem.
-// It returns invalid migrations by md5sum.
-func (m *Migrator) Verify(ctx context.Context) ([]PgMigration, error) {
-	// create migration table if not exists
-	if err := m.createMigratorTable(ctx); err != nil {
+// It returns invalid migrations by md5sum, applied migrations without local files and likely renamed migrations.
+func (m *Migrator) Verify(ctx context.Context) (*VerifyResult, error) {
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
 		return nil, err
+	} else if !ok {
+		return &VerifyResult{}, nil
 	}
 
+	return m.verify(ctx)
+}
+
+// verify returns invalid, missing and renamed migrations.
+func (m *Migrator) verify(ctx context.Context) (*VerifyResult, error) {
 	// read all Files
 	filenames, err := m.readAllFiles()
 	if err != nil {
@@ -396,11 +595,29 @@ func (m *Migrator) Verify(ctx context.Context) ([]PgMigration, error) {
 
 	// fetch completed migrations from db
 	var pm []PgMigration
-	if err = m.db.ModelContext(ctx, &pm).Where(`"filename" in (?)`, pg.In(filenames)).Select(); err != nil {
+	if err = m.whereVersioned(m.selectModel(ctx, &pm)).Order(`id`).Select(); err != nil {
 		return nil, fmt.Errorf("fetch completed migrations failed: %w", err)
 	}
 
-	return m.compareMD5Sum(mm.ToDB(), pm), nil
+	local := mm.ToDB()
+	localMapping := make(map[string]struct{}, len(local))
+	for _, l := range local {
+		localMapping[l.Filename] = struct{}{}
+	}
+
+	var completed, missing []PgMigration
+	for _, p := range pm {
+		if _, ok := localMapping[p.Filename]; ok {
+			completed = append(completed, p)
+		} else {
+			missing = append(missing, p)
+		}
+	}
+
+	res := VerifyResult{Invalid: m.compareMD5Sum(local, completed)}
+	res.Missing, res.Renamed = findRenamed(local, completed, missing)
+
+	return &res, nil
 }
 
 // compareMD5Sum compare md5 sum completed migrations with files in root dir
@@ -421,50 +638,75 @@ func (m *Migrator) compareMD5Sum(all, completed []PgMigration) (res []PgMigratio
 }
 
 // Redo rerun last migration
-func (m *Migrator) Redo(ctx context.Context, chCurrentFile chan string) (*PgMigration, error) {
+func (m *Migrator) Redo(ctx context.Context, obs Observer) (*PgMigration, error) {
+	var pm PgMigration
+	err := m.withLock(ctx, func(lm *Migrator) error {
+		return lm.redo(ctx, &pm, obs)
+	})
+	if err != nil && pm.ID == 0 {
+		return nil, err
+	}
+
+	return &pm, err
+}
+
+// redo deletes last migration from db and runs it again.
+func (m *Migrator) redo(ctx context.Context, pm *PgMigration, obs Observer) error {
 	// create migration table if not exists
 	if err := m.createMigratorTable(ctx); err != nil {
-		return nil, err
+		return err
 	}
 
 	// fetch last migration
-	var pm PgMigration
-	if err := m.db.ModelContext(ctx, &pm).Order(`id desc`).Limit(1).Select(); err != nil {
+	if err := m.whereVersioned(m.selectModel(ctx, pm)).Order(`id desc`).Limit(1).Select(); err != nil {
 		if errors.Is(err, pg.ErrNoRows) {
-			return nil, errors.New(`applied migrations were not found`)
+			return errors.New(`applied migrations were not found`)
 		}
-		return nil, fmt.Errorf(`fetch last migration failed: %w`, err)
+		return fmt.Errorf(`fetch last migration failed: %w`, err)
 	}
 
 	// check if migration file exists
-	if _, err := os.Stat(filepath.Join(m.rootDir, pm.Filename)); err != nil {
-		return nil, fmt.Errorf(`find migration file "%s" failed: %w`, pm.Filename, err)
+	if _, err := fs.Stat(m.fsys, pm.Filename); err != nil {
+		return fmt.Errorf(`find migration file "%s" failed: %w`, pm.Filename, err)
 	}
 
 	// delete last migration from DB
-	if _, err := m.db.ModelContext(ctx, &pm).WherePK().Delete(); err != nil {
-		return nil, fmt.Errorf(`delete "%s" from db failed: %w`, pm.Filename, err)
-	}
-
-	// Run(filename)
-	return &pm, m.Run(ctx, []string{pm.Filename}, chCurrentFile)
-}
-
-// createMigratorTable create if not exists migration table
-func (m *Migrator) createMigratorTable(ctx context.Context) error {
-	_, err := m.db.ExecContext(ctx, `
-		create table if not exists ?
-			(
-				id            serial                    not null,
-				filename      text                      not null,
-				"startedAt"   timestamptz default now() not null,
-				"finishedAt"  timestamptz,
-				transactional bool        default true  not null,
-				md5sum        varchar(32)               not null,
-				primary key ("id"),
-				unique ("filename")
-			)
-	`, pg.Ident(m.cfg.Table))
+	if _, err := m.db.ModelContext(ctx, pm).WherePK().Delete(); err != nil {
+		return fmt.Errorf(`delete "%s" from db failed: %w`, pm.Filename, err)
+	}
 
-	return err
+	// run(filename)
+	return m.run(ctx, []string{pm.Filename}, obs)
+}
+
+// tableExists checks if migration table exists using catalog.
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	table := string(m.db.Formatter().FormatQuery(nil, "?", pg.Ident(m.cfg.Table)))
+
+	var exists bool
+	if _, err := m.db.QueryOneContext(ctx, pg.Scan(&exists), `select to_regclass(?) is not null`, table); err != nil {
+		return false, fmt.Errorf("check migration table failed: %w", err)
+	}
+
+	return exists, nil
+}
+
+// Install creates migration table if not exists under migrator lock. It returns false if table already exists.
+func (m *Migrator) Install(ctx context.Context) (bool, error) {
+	var created bool
+	err := m.withLock(ctx, func(lm *Migrator) error {
+		ok, err := lm.tableExists(ctx)
+		if err != nil || ok {
+			return err
+		}
+
+		if err = lm.createMigratorTable(ctx); err != nil {
+			return fmt.Errorf("create migration table failed: %w", err)
+		}
+
+		created = true
+		return nil
+	})
+
+	return created, err
 }
@@ -2,8 +2,12 @@ package migrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/stretchr/testify/assert"
@@ -30,7 +34,7 @@ func NewTestDB() *pg.DB {
 	if err != nil {
 		panic(err)
 	}
-	return pg.Connect(ops)
+	return pg.Connect(NoticeOptions(ops))
 }
 
 func TestMain(m *testing.M) {
@@ -72,6 +76,45 @@ func TestMigrator_readFiles(t *testing.T) {
 	assert.Equal(t, want, filenames)
 }
 
+func TestNewMigratorFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2022-12-12-01-create-table-statuses.sql":     {Data: []byte(`CREATE TABLE "statuses" ("statusId" SERIAL NOT NULL);`)},
+		"2022-12-12-02-add-index-NONTR.sql":           {Data: []byte(`CREATE INDEX CONCURRENTLY ON "statuses" ("statusId");`)},
+		"2022-12-12-02-add-index-NONTR.down.sql":      {Data: []byte(`DROP INDEX "statuses_statusId_idx";`)},
+		"2022-12-12-03-fix-statuses-MANUAL.sql":       {Data: []byte(`DELETE FROM "statuses";`)},
+		"repeatable/v-statuses.sql":                   {Data: []byte(`CREATE OR REPLACE VIEW "v_statuses" AS SELECT 1;`)},
+		"2020/2020-01-01-01-create-table-archive.sql": {Data: []byte(`SELECT 1;`)},
+		"README.md": {Data: []byte(`migrations`)},
+	}
+
+	cfg := NewDefaultConfig()
+	cfg.RepeatableDir = "repeatable"
+	m := NewMigratorFS(nil, cfg, fsys)
+
+	filenames, err := m.readAllFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2022-12-12-01-create-table-statuses.sql", "2022-12-12-02-add-index-NONTR.sql"}, filenames)
+
+	mm, err := m.ReadMigrations(filenames)
+	require.NoError(t, err)
+	require.Len(t, mm, 2)
+	assert.False(t, mm[1].Transactional)
+
+	rr, err := m.readRepeatableFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"repeatable/v-statuses.sql"}, rr)
+
+	ff, err := m.IgnoredFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []IgnoredFile{
+		{Filename: "2020/2020-01-01-01-create-table-archive.sql", Reason: IgnoreReasonSubdirectory},
+		{Filename: "2022-12-12-03-fix-statuses-MANUAL.sql", Reason: IgnoreReasonManual},
+	}, ff)
+
+	_, err = m.NewMigrationFile("add users", "")
+	require.Error(t, err)
+}
+
 func TestMigrator_compareFilenames(t *testing.T) {
 	dirFiles := []string{
 		"2022-12-12-01-create-table-statuses.sql",
@@ -149,7 +192,7 @@ func TestMigrator_applyNonTransactionalMigration(t *testing.T) {
 	mg, err := NewMigration(testMigrator.rootDir, "2022-12-12-03-add-comments-news-NONTR.sql")
 	require.NoError(t, err)
 
-	err = testMigrator.applyNonTransactionalMigration(ctx, mg)
+	err = testMigrator.applyNonTransactionalMigration(ctx, mg, testObserver(t))
 	require.NoError(t, err)
 
 	var pm PgMigration
@@ -158,6 +201,19 @@ func TestMigrator_applyNonTransactionalMigration(t *testing.T) {
 	assert.NotEmpty(t, pm.FinishedAt)
 }
 
+func TestStatementError_Error(t *testing.T) {
+	err := &StatementError{
+		Index:     2,
+		Total:     3,
+		Statement: "CREATE INDEX CONCURRENTLY \"news_title_idx\"\n\tON \"news\" (\"title\")",
+		Err:       errors.New("relation \"news\" does not exist"),
+	}
+
+	assert.Equal(t, `statement 2 of 3 failed: relation "news" does not exist
+	CREATE INDEX CONCURRENTLY "news_title_idx" ON "news" ("title")
+statements 1-1 were applied, check them manually`, err.Error())
+}
+
 func TestMigrator_applyMigration(t *testing.T) {
 	t.Skip()
 	ctx := context.Background()
@@ -190,10 +246,7 @@ func execRun(ctx context.Context, t *testing.T) error {
 		return err
 	}
 
-	ch := make(chan string)
-	go readFromCh(ch, t)
-
-	return testMigrator.Run(ctx, filenames, ch)
+	return testMigrator.Run(ctx, filenames, testObserver(t))
 }
 
 func TestMigrator_Last(t *testing.T) {
@@ -233,10 +286,7 @@ func TestMigrator_Redo(t *testing.T) {
 		err := recreateSchema()
 		require.NoError(t, err)
 
-		ch := make(chan string)
-		go readFromCh(ch, t)
-
-		pm, err := testMigrator.Redo(ctx, ch)
+		pm, err := testMigrator.Redo(ctx, testObserver(t))
 		require.EqualError(t, err, "applied migrations were not found")
 		assert.Nil(t, pm)
 	})
@@ -251,10 +301,7 @@ func TestMigrator_Redo(t *testing.T) {
 		_, err = testDB.Exec("DROP TABLE tags CASCADE;")
 		require.NoError(t, err)
 
-		ch := make(chan string)
-		go readFromCh(ch, t)
-
-		pm, err := testMigrator.Redo(ctx, ch)
+		pm, err := testMigrator.Redo(ctx, testObserver(t))
 		require.NoError(t, err)
 		assert.Equal(t, &PgMigration{
 			ID:            pm.ID,
@@ -282,9 +329,7 @@ func TestMigrator_dryRunMigrations(t *testing.T) {
 	mm, err := testMigrator.newMigrations(dirFiles)
 	require.NoError(t, err)
 
-	ch := make(chan string)
-	go readFromCh(ch, t)
-	err = testMigrator.dryRunMigrations(ctx, mm, ch)
+	err = testMigrator.dryRunMigrations(ctx, mm, testObserver(t))
 	require.NoError(t, err)
 }
 
@@ -300,10 +345,7 @@ func TestMigrator_DryRun(t *testing.T) {
 			"2022-12-12-02-create-table-news.sql",
 		}
 
-		ch := make(chan string)
-		go readFromCh(ch, t)
-
-		err = testMigrator.DryRun(ctx, dirFiles, ch)
+		err = testMigrator.DryRun(ctx, dirFiles, testObserver(t))
 		require.NoError(t, err)
 	})
 
@@ -319,10 +361,7 @@ func TestMigrator_DryRun(t *testing.T) {
 			"2022-12-13-02-create-tags-table.sql",
 		}
 
-		ch := make(chan string)
-		go readFromCh(ch, t)
-
-		err = testMigrator.DryRun(ctx, dirFiles, ch)
+		err = testMigrator.DryRun(ctx, dirFiles, testObserver(t))
 		assert.EqualError(t, err, `non transactional migration found "2022-12-12-03-add-comments-news-NONTR.sql", run all migrations before it, please`)
 	})
 }
@@ -342,9 +381,7 @@ func TestMigrator_skipMigrations(t *testing.T) {
 	mm, err := testMigrator.newMigrations(dirFiles)
 	require.NoError(t, err)
 
-	ch := make(chan string)
-	go readFromCh(ch, t)
-	err = testMigrator.skipMigrations(ctx, mm, ch)
+	err = testMigrator.skipMigrations(ctx, mm, testObserver(t))
 	require.NoError(t, err)
 
 	for _, mg := range mm {
@@ -364,9 +401,7 @@ func TestMigrator_Skip(t *testing.T) {
 	filenames, err := testMigrator.Plan(ctx)
 	require.NoError(t, err)
 
-	ch := make(chan string)
-	go readFromCh(ch, t)
-	err = testMigrator.Skip(ctx, filenames, ch)
+	err = testMigrator.Skip(ctx, filenames, testObserver(t))
 	require.NoError(t, err)
 }
 
@@ -416,9 +451,10 @@ func TestMigrator_Verify(t *testing.T) {
 		err := recreateSchema()
 		require.NoError(t, err)
 
-		invalid, err := testMigrator.Verify(ctx)
+		res, err := testMigrator.Verify(ctx)
 		require.NoError(t, err)
-		assert.Empty(t, invalid)
+		assert.Empty(t, res.Invalid)
+		assert.Empty(t, res.Missing)
 	})
 
 	t.Run("correct migrations", func(t *testing.T) {
@@ -428,9 +464,11 @@ func TestMigrator_Verify(t *testing.T) {
 		err = execRun(ctx, t)
 		require.NoError(t, err)
 
-		invalid, err := testMigrator.Verify(ctx)
+		res, err := testMigrator.Verify(ctx)
 		require.NoError(t, err)
-		assert.Empty(t, invalid)
+		assert.Empty(t, res.Invalid)
+		assert.Empty(t, res.Missing)
+		assert.Empty(t, res.Renamed)
 	})
 
 	t.Run("invalid migrations", func(t *testing.T) {
@@ -445,19 +483,619 @@ func TestMigrator_Verify(t *testing.T) {
 		_, err = testMigrator.db.ModelContext(ctx, &pm).Column("md5sum").Where(`"filename" = ?`, invalidFilename).Update()
 		require.NoError(t, err)
 
-		invalid, err := testMigrator.Verify(ctx)
+		res, err := testMigrator.Verify(ctx)
 		require.NoError(t, err)
-		require.Len(t, invalid, 1)
-		assert.Equal(t, invalidFilename, invalid[0].Filename)
+		require.Len(t, res.Invalid, 1)
+		assert.Equal(t, invalidFilename, res.Invalid[0].Filename)
+	})
+
+	t.Run("missing and renamed migrations", func(t *testing.T) {
+		err := recreateSchema()
+		require.NoError(t, err)
+
+		err = execRun(ctx, t)
+		require.NoError(t, err)
+
+		// rename applied migration in db, so local file looks like renamed
+		renamedFilename := "2022-12-13-01-create-categories-table.sql"
+		pm := PgMigration{Filename: "2022-12-13-01-categories.sql"}
+		_, err = testMigrator.db.ModelContext(ctx, &pm).Column("filename").Where(`"filename" = ?`, renamedFilename).Update()
+		require.NoError(t, err)
+
+		// add applied migration without local file
+		_, err = testMigrator.db.ModelContext(ctx, &PgMigration{Filename: "2022-12-01-01-deleted.sql", Md5sum: "deleted", Transactional: true}).Insert()
+		require.NoError(t, err)
+
+		res, err := testMigrator.Verify(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, res.Invalid)
+		require.Len(t, res.Missing, 1)
+		assert.Equal(t, "2022-12-01-01-deleted.sql", res.Missing[0].Filename)
+		require.Len(t, res.Renamed, 1)
+		assert.Equal(t, "2022-12-13-01-categories.sql", res.Renamed[0].Filename)
+		assert.Equal(t, renamedFilename, res.Renamed[0].LocalFilename)
+
+		// rename back
+		_, err = testMigrator.Rename(ctx, res.Renamed[0].Filename, res.Renamed[0].LocalFilename)
+		require.NoError(t, err)
+
+		res, err = testMigrator.Verify(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, res.Renamed)
+		assert.Len(t, res.Missing, 1)
+	})
+}
+
+func TestFindRenamed(t *testing.T) {
+	local := []PgMigration{
+		{Filename: "2022-12-12-01-a.sql", Md5sum: "a"},
+		{Filename: "2022-12-12-02-b-renamed.sql", Md5sum: "b"},
+		{Filename: "2022-12-12-03-c.sql", Md5sum: "c"},
+	}
+	completed := []PgMigration{{Filename: "2022-12-12-01-a.sql", Md5sum: "a"}}
+	missing := []PgMigration{
+		{Filename: "2022-12-12-02-b.sql", Md5sum: "b"},
+		{Filename: "2022-12-12-04-d.sql", Md5sum: "d"},
+		{Filename: "2022-12-12-05-a-copy.sql", Md5sum: "a"},
+	}
+
+	gotMissing, gotRenamed := findRenamed(local, completed, missing)
+	assert.Equal(t, []PgMigration{missing[1], missing[2]}, gotMissing)
+	assert.Equal(t, []RenamedMigration{{PgMigration: missing[0], LocalFilename: "2022-12-12-02-b-renamed.sql"}}, gotRenamed)
+}
+
+func TestMigrator_Rename(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	err = execRun(ctx, t)
+	require.NoError(t, err)
+
+	_, err = testMigrator.Rename(ctx, "2022-12-13-01-create-categories-table.sql", "2022-12-13-05-unknown.sql")
+	require.EqualError(t, err, `migration file "2022-12-13-05-unknown.sql" was not found`)
+
+	_, err = testMigrator.Rename(ctx, "2022-12-13-01-create-categories-table.sql", "2022-12-13-02-create-tags-table.sql")
+	require.EqualError(t, err, `migration "2022-12-13-02-create-tags-table.sql" is already applied`)
+
+	_, err = testMigrator.Rename(ctx, "2022-12-13-05-unknown.sql", "2022-12-13-02-create-tags-table.sql")
+	require.Error(t, err)
+}
+
+func TestMigrator_withLock(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := NewDefaultConfig()
+	cfg.AdvisoryLockTimeout = "1s"
+	other := NewMigrator(testDB, cfg, "testdata")
+
+	err := testMigrator.withLock(ctx, func(*Migrator) error {
+		return other.withLock(ctx, func(*Migrator) error {
+			return nil
+		})
+	})
+
+	var lockErr *LockError
+	require.ErrorAs(t, err, &lockErr)
+	assert.Equal(t, cfg.Table, lockErr.Table)
+	require.Len(t, lockErr.Holders, 1)
+	assert.NotZero(t, lockErr.Holders[0].PID)
+
+	// lock is released
+	err = other.withLock(ctx, func(*Migrator) error {
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestMigrator_Resolve(t *testing.T) {
+	ctx := context.Background()
+	filename := "2022-12-12-03-add-comments-news-NONTR.sql"
+
+	// insert unfinished non-transactional migration
+	prepare := func(t *testing.T) {
+		err := recreateSchema()
+		require.NoError(t, err)
+		err = testMigrator.createMigratorTable(ctx)
+		require.NoError(t, err)
+
+		mg, err := NewMigration(testMigrator.rootDir, filename)
+		require.NoError(t, err)
+		_, err = testMigrator.db.ModelContext(ctx, mg.ToDB()).Insert()
+		require.NoError(t, err)
+	}
+
+	t.Run("plan with unfinished migration", func(t *testing.T) {
+		prepare(t)
+
+		_, err := testMigrator.Plan(ctx)
+		var ue *UnfinishedError
+		require.ErrorAs(t, err, &ue)
+		require.Len(t, ue.Migrations, 1)
+		assert.Equal(t, filename, ue.Migrations[0].Filename)
+
+		st, err := testMigrator.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, st.Applied)
+		assert.Len(t, st.Pending, 4)
+		assert.Len(t, st.Unfinished, 1)
+	})
+
+	t.Run("done", func(t *testing.T) {
+		prepare(t)
+
+		pm, err := testMigrator.Resolve(ctx, filename, ResolveDone)
+		require.NoError(t, err)
+		assert.NotNil(t, pm.FinishedAt)
+
+		plan, err := testMigrator.Plan(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, plan, filename)
+
+		_, err = testMigrator.Resolve(ctx, filename, ResolveDone)
+		require.Error(t, err)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		prepare(t)
+
+		_, err := testMigrator.Resolve(ctx, filename, ResolveRetry)
+		require.NoError(t, err)
+
+		plan, err := testMigrator.Plan(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, plan, filename)
+	})
+}
+
+func TestMigrator_Rollback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("plan", func(t *testing.T) {
+		err := recreateSchema()
+		require.NoError(t, err)
+		err = execRun(ctx, t)
+		require.NoError(t, err)
+
+		dm, err := testMigrator.RollbackPlan(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, dm, 3)
+		assert.Equal(t, "2022-12-13-02-create-tags-table.down.sql", dm[0].DownFilename)
+		assert.Equal(t, "2022-12-13-01-create-categories-table.down.sql", dm[1].DownFilename)
+		assert.Empty(t, dm[2].DownFilename)
+	})
+
+	t.Run("missing undo file", func(t *testing.T) {
+		_, err := testMigrator.Rollback(ctx, 3, testObserver(t))
+		require.EqualError(t, err, "undo files were not found for migrations: 2022-12-12-03-add-comments-news-NONTR.sql")
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		dm, err := testMigrator.Rollback(ctx, 2, testObserver(t))
+		require.NoError(t, err)
+		require.Len(t, dm, 2)
+
+		plan, err := testMigrator.Plan(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"2022-12-13-01-create-categories-table.sql",
+			"2022-12-13-02-create-tags-table.sql",
+		}, plan)
 	})
 }
 
-func readFromCh(ch chan string, t *testing.T) {
-	for x := range ch {
-		t.Log(x)
+func TestMigrator_RollbackCount(t *testing.T) {
+	ctx := context.Background()
+	m := NewMigratorFS(nil, NewDefaultConfig(), fstest.MapFS{})
+
+	for _, n := range []int{0, -1} {
+		_, err := m.RollbackPlan(ctx, n)
+		require.EqualError(t, err, fmt.Sprintf("invalid count %d, it must be positive", n))
+
+		_, err = m.Rollback(ctx, n, nil)
+		require.EqualError(t, err, fmt.Sprintf("invalid count %d, it must be positive", n))
 	}
 }
 
+func TestMigrator_runRepeatable(t *testing.T) {
+	ctx := context.Background()
+	filename := "repeatable/v-published-news.sql"
+
+	cfg := NewDefaultConfig()
+	cfg.RepeatableDir = "repeatable"
+	mg := NewMigrator(testDB, cfg, "testdata")
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	rr, err := mg.PlanRepeatable(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filename}, rr)
+
+	filenames, err := mg.Plan(ctx)
+	require.NoError(t, err)
+
+	err = mg.Run(ctx, filenames, testObserver(t))
+	require.NoError(t, err)
+
+	// applied
+	rr, err = mg.PlanRepeatable(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rr)
+
+	last, err := mg.Last(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, last, 5)
+
+	applied, err := mg.LastRepeatable(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, filename, applied[0].Filename)
+
+	// changed
+	pm := PgMigration{Md5sum: "changed"}
+	_, err = mg.db.ModelContext(ctx, &pm).Column("md5sum").Where(`"filename" = ?`, filename).Update()
+	require.NoError(t, err)
+
+	rr, err = mg.PlanRepeatable(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filename}, rr)
+
+	err = mg.Run(ctx, nil, testObserver(t))
+	require.NoError(t, err)
+
+	rr, err = mg.PlanRepeatable(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rr)
+}
+
+func TestMigrator_Check(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not initialized", func(t *testing.T) {
+		err := recreateSchema()
+		require.NoError(t, err)
+
+		res, err := testMigrator.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, res.Initialized)
+		assert.Len(t, res.Pending, 5)
+		assert.False(t, res.OK())
+
+		exists, err := testMigrator.tableExists(ctx)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("all applied", func(t *testing.T) {
+		err := recreateSchema()
+		require.NoError(t, err)
+		err = execRun(ctx, t)
+		require.NoError(t, err)
+
+		res, err := testMigrator.Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, res.Initialized)
+		assert.True(t, res.OK())
+	})
+}
+
+func TestMigrator_Install(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	// read-only methods do not create table
+	plan, err := testMigrator.Plan(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plan, 5)
+
+	st, err := testMigrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, st.Initialized)
+
+	exists, err := testMigrator.tableExists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	created, err := testMigrator.Install(ctx)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = testMigrator.Install(ctx)
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	// schema table is left after migrations table was dropped
+	_, err = testDB.Exec(`drop table "pgMigrations"`)
+	require.NoError(t, err)
+
+	version, changes, err := testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	require.Len(t, changes, SchemaVersion)
+
+	created, err = testMigrator.Install(ctx)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	exists, err = testMigrator.tableExists(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	version, changes, err = testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+	assert.Empty(t, changes)
+}
+
+func TestMigrator_UpgradeTable(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	// no table
+	version, changes, err := testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	require.Len(t, changes, SchemaVersion)
+	assert.Contains(t, changes[0].Queries[0], `create table if not exists "public"."pgMigrationsSchema"`)
+	assert.Contains(t, changes[0].Queries[2], `create table if not exists "public"."pgMigrations"`)
+
+	// table of previous version without audit columns
+	_, err = testDB.Exec(`
+		create table "pgMigrations"
+			(
+				id            serial                    not null,
+				filename      text                      not null,
+				"startedAt"   timestamptz default now() not null,
+				"finishedAt"  timestamptz,
+				transactional bool        default true  not null,
+				md5sum        varchar(32)               not null,
+				primary key ("id"),
+				unique ("filename")
+			);
+		insert into "pgMigrations" (filename, "finishedAt", md5sum) values ('2022-12-12-01-create-table-statuses.sql', now(), '');
+		comment on table "pgMigrations" is 'applied migrations';
+	`)
+	require.NoError(t, err)
+
+	// read-only methods work before upgrade
+	list, err := testMigrator.Last(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].DBUser)
+
+	// dry run
+	version, changes, err = testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	require.Len(t, changes, SchemaVersion-1)
+	assert.Equal(t, 2, changes[0].Version)
+	assert.Contains(t, changes[0].Queries, `alter table "public"."pgMigrations" add column if not exists "dbUser" text`)
+
+	columns, err := testMigrator.tableColumns(ctx)
+	require.NoError(t, err)
+	assert.False(t, columns["dbUser"])
+
+	// upgrade
+	planned := changes
+	changes, err = testMigrator.UpgradeTable(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, planned, changes)
+
+	columns, err = testMigrator.tableColumns(ctx)
+	require.NoError(t, err)
+	for _, c := range append(auditColumns, "notices") {
+		assert.True(t, columns[c], c)
+	}
+
+	version, changes, err = testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+	assert.Empty(t, changes)
+
+	// table comment is not changed
+	var comment string
+	_, err = testDB.QueryOne(pg.Scan(&comment), `select obj_description('"pgMigrations"'::regclass, 'pg_class')`)
+	require.NoError(t, err)
+	assert.Equal(t, "applied migrations", comment)
+
+	// existing migrations are not attributed to current role
+	list, err = testMigrator.Last(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].DBUser)
+
+	// all changes are idempotent
+	for _, ch := range planned {
+		require.NoError(t, testMigrator.applySchemaChange(ctx, ch))
+	}
+
+	// version is detected by columns without schema table
+	_, err = testDB.Exec(`drop table "pgMigrationsSchema"`)
+	require.NoError(t, err)
+
+	changes, err = testMigrator.UpgradeTable(ctx)
+	require.NoError(t, err)
+	assert.Len(t, changes, SchemaVersion-3)
+
+	version, changes, err = testMigrator.SchemaPlan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+	assert.Empty(t, changes)
+}
+
+func TestMigrator_Audit(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	cfg := NewDefaultConfig()
+	cfg.Version, cfg.Revision = "v1.2.3", "abc123"
+	m := NewMigrator(testDB, cfg, "testdata")
+
+	err = m.Run(ctx, []string{"2022-12-12-01-create-table-statuses.sql"}, testObserver(t))
+	require.NoError(t, err)
+
+	list, err := m.Last(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, testDB.Options().User, list[0].DBUser)
+	assert.Equal(t, m.audit.osUser, list[0].OSUser)
+	assert.Equal(t, m.audit.hostname, list[0].Hostname)
+	assert.Equal(t, "v1.2.3", list[0].Version)
+	assert.Equal(t, "abc123", list[0].Revision)
+}
+
+func TestMigrator_Notices(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"2022-12-12-01-notice.sql": {Data: []byte(`do $$ begin raise notice 'hello'; end $$;`)},
+	}
+	cfg := NewDefaultConfig()
+	cfg.StoreNotices = true
+
+	var notices []string
+	obs := ObserverFunc(func(e Event) {
+		if e.Type == EventNotice {
+			notices = append(notices, e.Message)
+		}
+	})
+
+	// db without NoticeOptions, migration is applied without notices
+	opt := *testDB.Options()
+	opt.Dialer = nil
+	db := pg.Connect(&opt)
+	defer db.Close()
+
+	m := NewMigratorFS(db, cfg, fsys)
+	err = m.DryRun(ctx, []string{"2022-12-12-01-notice.sql"}, obs)
+	require.NoError(t, err)
+	assert.Empty(t, notices)
+
+	// db with NoticeOptions
+	m = NewMigratorFS(testDB, cfg, fsys)
+	err = m.Run(ctx, []string{"2022-12-12-01-notice.sql"}, obs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NOTICE: hello"}, notices)
+
+	var stored string
+	_, err = testDB.QueryOne(pg.Scan(&stored), `select "notices" from "pgMigrations" where "filename" = ?`, "2022-12-12-01-notice.sql")
+	require.NoError(t, err)
+	assert.Equal(t, "NOTICE: hello", stored)
+}
+
+func TestMigrator_History(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"2022-12-12-01-fail.sql": {Data: []byte(`select 1/0;`)},
+	}
+	m := NewMigratorFS(testDB, NewDefaultConfig(), fsys)
+
+	// no history table
+	aa, err := m.History(ctx, "2022-12-12-01-fail.sql")
+	require.NoError(t, err)
+	assert.Empty(t, aa)
+
+	err = m.Run(ctx, []string{"2022-12-12-01-fail.sql"}, nil)
+	require.Error(t, err)
+
+	// fix migration and run again
+	fsys["2022-12-12-01-fail.sql"] = &fstest.MapFile{Data: []byte(`select 1;`)}
+	err = m.Run(ctx, []string{"2022-12-12-01-fail.sql"}, nil)
+	require.NoError(t, err)
+
+	aa, err = m.History(ctx, "2022-12-12-01-fail.sql")
+	require.NoError(t, err)
+	require.Len(t, aa, 2)
+	assert.Equal(t, AttemptFailed, aa[0].Status)
+	assert.Equal(t, "22012", aa[0].SQLState)
+	assert.Equal(t, "division by zero", aa[0].Error)
+	assert.NotEmpty(t, aa[0].DBUser)
+	assert.Equal(t, AttemptDone, aa[1].Status)
+	assert.Empty(t, aa[1].Error)
+
+	aa, err = m.LastAttempts(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, aa, 1)
+	assert.Equal(t, AttemptDone, aa[0].Status)
+
+	// failed rollback
+	fsys["2022-12-12-01-fail.down.sql"] = &fstest.MapFile{Data: []byte(`select 1/0;`)}
+	_, err = m.Rollback(ctx, 1, nil)
+	require.Error(t, err)
+
+	aa, err = m.History(ctx, "2022-12-12-01-fail.down.sql")
+	require.NoError(t, err)
+	require.Len(t, aa, 1)
+	assert.Equal(t, AttemptFailed, aa[0].Status)
+	assert.Equal(t, "22012", aa[0].SQLState)
+}
+
+func TestMigrator_HistoryPoolSize(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	// pgmigrator init writes PoolSize = 1, locked connection is the only one
+	opt := *testDB.Options()
+	opt.PoolSize, opt.PoolTimeout = 1, time.Second
+	db := pg.Connect(&opt)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"2022-12-12-01-fail.sql":     {Data: []byte(`select 1/0;`)},
+		"2022-12-12-02-statuses.sql": {Data: []byte(`create table statuses (id int);`)},
+		"2022-12-12-03-sleep.sql":    {Data: []byte("-- pgmigrator: statement_timeout=100ms\nselect pg_sleep(1);")},
+	}
+	m := NewMigratorFS(db, NewDefaultConfig(), fsys)
+
+	err = m.Run(ctx, []string{"2022-12-12-01-fail.sql"}, nil)
+	require.Error(t, err)
+
+	err = m.Run(ctx, []string{"2022-12-12-02-statuses.sql"}, nil)
+	require.NoError(t, err)
+
+	// canceled statement closes locked connection, attempt is saved by dedicated connection
+	err = m.Run(ctx, []string{"2022-12-12-03-sleep.sql"}, nil)
+	require.Error(t, err)
+
+	aa, err := m.LastAttempts(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, aa, 3)
+	assert.Equal(t, AttemptFailed, aa[0].Status)
+	assert.Equal(t, "57014", aa[0].SQLState)
+	assert.Equal(t, AttemptDone, aa[1].Status)
+	assert.Equal(t, AttemptFailed, aa[2].Status)
+	assert.Equal(t, "22012", aa[2].SQLState)
+}
+
+func TestDownFilename(t *testing.T) {
+	assert.Equal(t, "2022-12-13-01-create-categories-table.down.sql", downFilename("2022-12-13-01-create-categories-table.sql"))
+	assert.True(t, isDownFile("2022-12-13-01-create-categories-table.down.sql"))
+	assert.False(t, isDownFile("2022-12-13-01-create-categories-table.sql"))
+}
+
+func testObserver(t *testing.T) Observer {
+	return ObserverFunc(func(e Event) {
+		t.Log(e.Type, e.Filename, e.Duration, e.Err)
+	})
+}
+
 func recreateSchema() error {
 	_, err := testDB.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA PUBLIC;")
 	return err
@@ -3,8 +3,8 @@ package migrator
 import (
 	"crypto/md5"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 )
@@ -13,26 +13,76 @@ type Config struct {
 	Table            string
 	StatementTimeout string
 	FileMask         string
+
+	// ConnectTimeout is a max wait time for database to accept connections before command starts.
+	// ConnectRetries is a max attempts count, 0 means unlimited within ConnectTimeout.
+	// Empty ConnectTimeout and zero ConnectRetries mean no waiting.
+	ConnectTimeout string
+	ConnectRetries int
+
+	// AdvisoryLockTimeout is a max wait time for advisory lock held by another pgmigrator process.
+	// Empty value means no waiting.
+	AdvisoryLockTimeout string
+
+	// LockTimeout is a lock_timeout for migrations, can be overridden by lock_timeout directive.
+	// Empty value means no timeout.
+	LockTimeout string
+
+	// LockRetryAttempts is a max attempts count for migration failed with lock_timeout.
+	// Next attempt starts after LockRetryBackoff, which doubles after each attempt up to LockRetryMaxBackoff,
+	// plus random jitter up to LockRetryJitter of backoff.
+	LockRetryAttempts   int
+	LockRetryBackoff    string
+	LockRetryMaxBackoff string
+	LockRetryJitter     float64
+
+	// OutOfOrder is a policy for pending migrations which sort before the last applied migration:
+	// allow - apply silently, warn - apply with warning, deny - fail run.
+	OutOfOrder string
+
+	// RepeatableDir is a subdirectory with repeatable migrations (views, functions, triggers),
+	// which are applied again when its md5sum changes. Empty value disables repeatable migrations.
+	RepeatableDir string
+
+	// StoreNotices enables saving of PostgreSQL notices of applied migration to "notices" column of migrations table.
+	StoreNotices bool
+
+	// Version of pgmigrator and Revision (git commit or CI job id) are saved with each applied migration.
+	Version  string `toml:"-"`
+	Revision string `toml:"-"`
 }
 
 func NewDefaultConfig() Config {
 	return Config{
-		Table:            "public.pgMigrations",
-		StatementTimeout: "5s",
-		FileMask:         `\d{4}-\d{2}-\d{2}-\S+.sql`,
+		Table:               "public.pgMigrations",
+		StatementTimeout:    "5s",
+		FileMask:            `\d{4}-\d{2}-\d{2}-\S+.sql`,
+		AdvisoryLockTimeout: "30s",
+		LockRetryAttempts:   3,
+		LockRetryBackoff:    "1s",
+		LockRetryMaxBackoff: "30s",
+		LockRetryJitter:     0.2,
+		OutOfOrder:          OutOfOrderWarn,
 	}
 }
 
 type PgMigration struct {
 	tableName struct{} `pg:"?migrationTable,alias:t,discard_unknown_columns"` //nolint:all
 
-	ID            int        `pg:"id,pk"`
-	Filename      string     `pg:"filename,use_zero"`
-	StartedAt     time.Time  `pg:"startedAt,use_zero"`
-	FinishedAt    *time.Time `pg:"finishedAt"`
-	Transactional bool       `pg:"transactional,use_zero"`
-	Md5sum        string     `pg:"md5sum,use_zero"`
-	Md5sumLocal   string     `pg:"-"`
+	ID            int        `pg:"id,pk" json:"id"`
+	Filename      string     `pg:"filename,use_zero" json:"filename"`
+	StartedAt     time.Time  `pg:"startedAt,use_zero" json:"startedAt"`
+	FinishedAt    *time.Time `pg:"finishedAt" json:"finishedAt"`
+	Transactional bool       `pg:"transactional,use_zero" json:"transactional"`
+	Md5sum        string     `pg:"md5sum,use_zero" json:"md5sum"`
+	Md5sumLocal   string     `pg:"-" json:"md5sumLocal,omitempty"`
+
+	// audit, empty for migrations applied by previous versions
+	DBUser   string `pg:"dbUser" json:"dbUser,omitempty"` // current_user, set by column default
+	OSUser   string `pg:"osUser" json:"osUser,omitempty"`
+	Hostname string `pg:"hostname" json:"hostname,omitempty"`
+	Version  string `pg:"version" json:"version,omitempty"`   // pgmigrator version
+	Revision string `pg:"revision" json:"revision,omitempty"` // git commit or CI job id
 }
 
 type Migration struct {
@@ -40,10 +90,21 @@ type Migration struct {
 	Data          []byte
 	Md5Sum        string
 	Transactional bool
+
+	// header directives, see parseHeader
+	StatementTimeout string
+	LockTimeout      string
+	Tags             []string
 }
 
+// NewMigration reads migration file from rootDir.
 func NewMigration(rootDir, filename string) (Migration, error) {
-	f, err := os.ReadFile(filepath.Join(rootDir, filename))
+	return NewMigrationFS(os.DirFS(rootDir), filename)
+}
+
+// NewMigrationFS reads migration file from fsys.
+func NewMigrationFS(fsys fs.FS, filename string) (Migration, error) {
+	f, err := fs.ReadFile(fsys, filename)
 	if err != nil {
 		return Migration{Filename: filename}, err
 	}
@@ -55,6 +116,10 @@ func NewMigration(rootDir, filename string) (Migration, error) {
 		Transactional: !strings.HasSuffix(filename, "NONTR.sql"),
 	}
 
+	if err = m.parseHeader(); err != nil {
+		return m, fmt.Errorf("invalid header: %w", err)
+	}
+
 	return m, nil
 }
 
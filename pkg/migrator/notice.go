@@ -0,0 +1,306 @@
+package migrator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Notice is a NOTICE or WARNING message from PostgreSQL, e.g. from RAISE NOTICE.
+type Notice struct {
+	Severity string
+	Message  string
+	Detail   string
+	Hint     string
+}
+
+func (n Notice) String() string {
+	s := n.Severity + ": " + n.Message
+	if n.Detail != "" {
+		s += "\nDETAIL: " + n.Detail
+	}
+	if n.Hint != "" {
+		s += "\nHINT: " + n.Hint
+	}
+
+	return s
+}
+
+// parseNotice parses body of NoticeResponse message: fields with type byte and null-terminated value.
+func parseNotice(b []byte) Notice {
+	var n Notice
+	for len(b) > 1 {
+		typ := b[0]
+		i := bytes.IndexByte(b[1:], 0)
+		if i < 0 {
+			break
+		}
+
+		v := string(b[1 : i+1])
+		b = b[i+2:]
+
+		switch typ {
+		case 'V':
+			n.Severity = v
+		case 'S':
+			if n.Severity == "" {
+				n.Severity = v
+			}
+		case 'M':
+			n.Message = v
+		case 'D':
+			n.Detail = v
+		case 'H':
+			n.Hint = v
+		}
+	}
+
+	return n
+}
+
+// noticeBackend identifies server process of connection by address and backend pid.
+type noticeBackend struct {
+	addr string
+	pid  int32
+}
+
+// noticeHub sends notices of connections created with NoticeOptions to listeners of their backends.
+// Migrations are applied one by one on connection which holds migrator lock, so single listener per backend is enough.
+type noticeHub struct {
+	mu       sync.Mutex
+	backends map[noticeBackend]func(Notice) // connected backends with current listener, nil if nobody listens
+}
+
+// backendNotices receives notices of all connections created with NoticeOptions.
+var backendNotices = &noticeHub{}
+
+// connect registers backend of new connection.
+func (h *noticeHub) connect(b noticeBackend) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.backends == nil {
+		h.backends = make(map[noticeBackend]func(Notice))
+	}
+	if _, ok := h.backends[b]; !ok {
+		h.backends[b] = nil
+	}
+}
+
+// disconnect removes backend of closed connection.
+func (h *noticeHub) disconnect(b noticeBackend) {
+	h.mu.Lock()
+	delete(h.backends, b)
+	h.mu.Unlock()
+}
+
+// listen sets current listener of backend, returned func removes it.
+// It returns false if connection of backend was not created with NoticeOptions.
+func (h *noticeHub) listen(b noticeBackend, fn func(Notice)) (func(), bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.backends[b]; !ok {
+		return func() {}, false
+	}
+
+	h.backends[b] = fn
+	return func() {
+		h.mu.Lock()
+		if _, ok := h.backends[b]; ok {
+			h.backends[b] = nil
+		}
+		h.mu.Unlock()
+	}, true
+}
+
+func (h *noticeHub) send(b noticeBackend, n Notice) {
+	h.mu.Lock()
+	fn := h.backends[b]
+	h.mu.Unlock()
+
+	if fn != nil {
+		fn(n)
+	}
+}
+
+// sslRequestCode is a code of SSLRequest message of PostgreSQL protocol.
+const sslRequestCode = 80877103
+
+// NoticeOptions returns copy of options with dialer which captures PostgreSQL notices (e.g. RAISE NOTICE) for migrator,
+// go-pg discards them. Create db for migrator with it:
+//
+//	db := pg.Connect(migrator.NoticeOptions(opt))
+//
+// TLS is started by dialer instead of go-pg, so notices are read after decryption and TLSConfig of copy is nil.
+func NoticeOptions(opt *pg.Options) *pg.Options {
+	res := *opt
+	dial, tlsConfig := res.Dialer, res.TLSConfig
+	if dial == nil {
+		// default dialer of go-pg, DialTimeout is set by pg.Connect
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			netDialer := &net.Dialer{Timeout: res.DialTimeout, KeepAlive: 5 * time.Minute}
+			return netDialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	res.TLSConfig = nil
+	res.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConfig != nil {
+			tlsConn, err := startTLS(conn, tlsConfig, res.DialTimeout)
+			if err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			conn = tlsConn
+		}
+
+		return &noticeConn{Conn: conn, hub: backendNotices, addr: addr}, nil
+	}
+
+	return &res
+}
+
+// startTLS sends SSLRequest and starts TLS on conn like go-pg does for Options.TLSConfig.
+func startTLS(conn net.Conn, cfg *tls.Config, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	binary.BigEndian.PutUint32(b[4:], sslRequestCode)
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, b[:1]); err != nil {
+		return nil, err
+	} else if b[0] != 'S' {
+		return nil, errors.New("pg: SSL is not enabled on the server")
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return tls.Client(conn, cfg), nil
+}
+
+// noticeConn parses backend messages read by go-pg: BackendKeyData registers connection in hub,
+// NoticeResponse messages are sent to hub. Read data is not changed.
+type noticeConn struct {
+	net.Conn
+	hub     *noticeHub
+	addr    string
+	backend noticeBackend // set by BackendKeyData message
+
+	header  [5]byte // message type and length
+	headerN int
+	left    int    // unread bytes of current message body
+	body    []byte // body of current BackendKeyData or NoticeResponse message
+}
+
+func (c *noticeConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.parse(p[:n])
+
+	return n, err
+}
+
+func (c *noticeConn) Close() error {
+	if c.backend.pid != 0 {
+		c.hub.disconnect(c.backend)
+	}
+
+	return c.Conn.Close()
+}
+
+func (c *noticeConn) parse(b []byte) {
+	for len(b) > 0 {
+		if c.headerN < len(c.header) {
+			k := copy(c.header[c.headerN:], b)
+			c.headerN += k
+			b = b[k:]
+			if c.headerN < len(c.header) {
+				return
+			}
+
+			c.left = max(int(binary.BigEndian.Uint32(c.header[1:]))-4, 0)
+			c.body = c.body[:0]
+		}
+
+		typ := c.header[0]
+		k := min(c.left, len(b))
+		if typ == 'N' || typ == 'K' {
+			c.body = append(c.body, b[:k]...)
+		}
+		c.left -= k
+		b = b[k:]
+
+		if c.left == 0 {
+			switch {
+			case typ == 'N':
+				c.hub.send(c.backend, parseNotice(c.body))
+			case typ == 'K' && len(c.body) >= 4:
+				c.backend = noticeBackend{addr: c.addr, pid: int32(binary.BigEndian.Uint32(c.body))}
+				c.hub.connect(c.backend)
+			}
+			c.headerN = 0
+		}
+	}
+}
+
+// trackWithNotices works like track and sends notices received during fn as EventNotice.
+// It returns received notices.
+// Notices are not captured if db was not created with NoticeOptions, it is logged once.
+func (m *Migrator) trackWithNotices(obs Observer, filename string, fn func() error) ([]string, error) {
+	var notices []string
+	stop, ok := backendNotices.listen(m.backend, func(n Notice) {
+		notices = append(notices, n.String())
+		notify(obs, Event{Type: EventNotice, Filename: filename, Message: n.String()})
+	})
+	defer stop()
+
+	if !ok && m.pool != nil {
+		m.noticeWarning.Do(func() {
+			log.Print("warning: PostgreSQL notices are not captured, create db with migrator.NoticeOptions")
+		})
+	}
+
+	err := track(obs, filename, fn)
+
+	return notices, err
+}
+
+// saveNotices stores notices of applied migration if StoreNotices is enabled.
+func (m *Migrator) saveNotices(ctx context.Context, filename string, notices []string) error {
+	if !m.cfg.StoreNotices || len(notices) == 0 {
+		return nil
+	}
+
+	_, err := m.db.ExecContext(ctx, `update ? set "notices" = ? where "filename" = ?`, pg.Ident(m.cfg.Table), strings.Join(notices, "\n"), filename)
+	if err != nil {
+		return fmt.Errorf(`save notices failed: %w`, err)
+	}
+
+	return nil
+}
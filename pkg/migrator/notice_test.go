@@ -0,0 +1,151 @@
+package migrator
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMessage returns backend message with type and body.
+func testMessage(typ byte, body []byte) []byte {
+	b := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(body)+4))
+	return append(b, body...)
+}
+
+func testNoticeBody(severity, message, hint string) []byte {
+	b := []byte("S" + severity + "\x00V" + severity + "\x00C00000\x00M" + message + "\x00")
+	if hint != "" {
+		b = append(b, "H"+hint+"\x00"...)
+	}
+	return append(b, 0)
+}
+
+func TestParseNotice(t *testing.T) {
+	n := parseNotice(testNoticeBody("NOTICE", `relation "statuses" already exists, skipping`, ""))
+	assert.Equal(t, Notice{Severity: "NOTICE", Message: `relation "statuses" already exists, skipping`}, n)
+	assert.Equal(t, `NOTICE: relation "statuses" already exists, skipping`, n.String())
+
+	n = parseNotice(testNoticeBody("WARNING", "there is no transaction in progress", "use begin"))
+	assert.Equal(t, "WARNING: there is no transaction in progress\nHINT: use begin", n.String())
+
+	// broken body
+	assert.Equal(t, Notice{}, parseNotice([]byte("Mbroken")))
+}
+
+func TestNoticeConn_parse(t *testing.T) {
+	var notices []Notice
+	h := &noticeHub{}
+	b := noticeBackend{addr: "db:5432", pid: 42}
+	h.connect(b)
+	stop, ok := h.listen(b, func(n Notice) { notices = append(notices, n) })
+	require.True(t, ok)
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint32(key, 42)
+
+	var stream []byte
+	stream = append(stream, testMessage('K', key)...)
+	stream = append(stream, testMessage('C', []byte("CREATE TABLE\x00"))...)
+	stream = append(stream, testMessage('N', testNoticeBody("NOTICE", "first", ""))...)
+	stream = append(stream, testMessage('Z', []byte("I"))...)
+	stream = append(stream, testMessage('N', testNoticeBody("WARNING", "second", "hint"))...)
+	stream = append(stream, testMessage('Z', []byte("I"))...)
+
+	for _, size := range []int{1, 3, 7, len(stream)} {
+		notices = nil
+		c := &noticeConn{hub: h, addr: "db:5432"}
+		for b := stream; len(b) > 0; {
+			k := min(size, len(b))
+			c.parse(b[:k])
+			b = b[k:]
+		}
+
+		assert.Equal(t, b, c.backend)
+		require.Len(t, notices, 2, "chunk size %d", size)
+		assert.Equal(t, Notice{Severity: "NOTICE", Message: "first"}, notices[0])
+		assert.Equal(t, Notice{Severity: "WARNING", Message: "second", Hint: "hint"}, notices[1])
+	}
+
+	// other backend
+	notices = nil
+	(&noticeConn{hub: h, addr: "db:5433"}).parse(stream)
+	assert.Empty(t, notices)
+
+	// no listener
+	stop()
+	(&noticeConn{hub: h, addr: "db:5432"}).parse(stream)
+	assert.Empty(t, notices)
+
+	// closed connection
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &noticeConn{Conn: client, hub: h, addr: "db:5432"}
+	c.parse(stream[:len(testMessage('K', key))])
+	require.NoError(t, c.Close())
+	_, ok = h.listen(b, func(Notice) {})
+	assert.False(t, ok)
+}
+
+// testSSLDialer returns dialer of pipe connection, server reads SSLRequest and replies with answer.
+func testSSLDialer(t *testing.T, answer byte) func(context.Context, string, string) (net.Conn, error) {
+	return func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			b := make([]byte, 8)
+			if _, err := io.ReadFull(server, b); err != nil {
+				return
+			}
+			assert.Equal(t, uint32(8), binary.BigEndian.Uint32(b))
+			assert.Equal(t, uint32(sslRequestCode), binary.BigEndian.Uint32(b[4:]))
+			_, _ = server.Write([]byte{answer})
+		}()
+
+		return client, nil
+	}
+}
+
+func TestNoticeOptions(t *testing.T) {
+	// options are not changed
+	opt := &pg.Options{Addr: "db:5432", Dialer: testSSLDialer(t, 'S'), TLSConfig: &tls.Config{ServerName: "db"}}
+	res := NoticeOptions(opt)
+	assert.NotNil(t, opt.TLSConfig)
+	assert.Nil(t, res.TLSConfig)
+
+	// plain connection
+	plain := func(context.Context, string, string) (net.Conn, error) {
+		client, _ := net.Pipe()
+		return client, nil
+	}
+	conn, err := NoticeOptions(&pg.Options{Dialer: plain}).Dialer(context.Background(), "tcp", "db:5432")
+	require.NoError(t, err)
+	require.IsType(t, &noticeConn{}, conn)
+	assert.Equal(t, "db:5432", conn.(*noticeConn).addr)
+	_ = conn.Close()
+
+	// tls is started before notices are parsed
+	conn, err = res.Dialer(context.Background(), "tcp", "db:5432")
+	require.NoError(t, err)
+	require.IsType(t, &noticeConn{}, conn)
+	assert.IsType(t, &tls.Conn{}, conn.(*noticeConn).Conn)
+	_ = conn.Close()
+
+	// tls is not enabled on server
+	opt.Dialer = testSSLDialer(t, 'N')
+	_, err = NoticeOptions(opt).Dialer(context.Background(), "tcp", "db:5432")
+	require.EqualError(t, err, "pg: SSL is not enabled on the server")
+
+	// migrator uses db of caller
+	db := pg.Connect(&pg.Options{Addr: "127.0.0.1:1"})
+	defer db.Close()
+	m := NewMigratorFS(db, NewDefaultConfig(), fstest.MapFS{})
+	assert.Same(t, db.Options(), m.pool.Options())
+}
@@ -0,0 +1,74 @@
+package migrator
+
+import (
+	"time"
+)
+
+// EventType is a type of migration event.
+type EventType string
+
+const (
+	// EventStarted is sent before migration file is applied.
+	EventStarted EventType = "started"
+
+	// EventStatement is sent before statement of non-transactional migration is executed, Statement and Total are set.
+	EventStatement EventType = "statement"
+
+	// EventNotice is sent when PostgreSQL returns NOTICE or WARNING message during migration, Message is set.
+	EventNotice EventType = "notice"
+
+	// EventFinished is sent after migration file was applied, Duration is set.
+	EventFinished EventType = "finished"
+
+	// EventFailed is sent after migration file failed, Duration and Err are set.
+	EventFailed EventType = "failed"
+
+	// EventSkipped is sent when migration file is marked as applied without running, see Skip.
+	EventSkipped EventType = "skipped"
+)
+
+// Event is a migration progress event.
+type Event struct {
+	Type      EventType
+	Filename  string
+	Statement int // number of statement in non-transactional migration, starts from 1
+	Total     int // count of statements in non-transactional migration
+	Message   string
+	Duration  time.Duration
+	Err       error
+}
+
+// Observer receives migration events. Events are sent synchronously from the goroutine which runs migrations.
+type Observer interface {
+	OnEvent(e Event)
+}
+
+// ObserverFunc is an adapter to use function as Observer.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// notify sends event to observer, nil observer is allowed.
+func notify(obs Observer, e Event) {
+	if obs != nil {
+		obs.OnEvent(e)
+	}
+}
+
+// track sends EventStarted, runs fn and sends EventFinished or EventFailed with duration of fn.
+func track(obs Observer, filename string, fn func() error) error {
+	notify(obs, Event{Type: EventStarted, Filename: filename})
+
+	start := time.Now()
+	err := fn()
+
+	e := Event{Type: EventFinished, Filename: filename, Duration: time.Since(start)}
+	if err != nil {
+		e.Type, e.Err = EventFailed, err
+	}
+	notify(obs, e)
+
+	return err
+}
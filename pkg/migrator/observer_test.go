@@ -0,0 +1,32 @@
+package migrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrack(t *testing.T) {
+	var events []Event
+	obs := ObserverFunc(func(e Event) { events = append(events, e) })
+
+	err := track(obs, "2022-12-12-01-create-table-statuses.sql", func() error { return nil })
+	require.NoError(t, err)
+
+	errFailed := errors.New("syntax error")
+	err = track(obs, "2022-12-12-02-create-table-news.sql", func() error { return errFailed })
+	require.ErrorIs(t, err, errFailed)
+
+	require.Len(t, events, 4)
+	assert.Equal(t, Event{Type: EventStarted, Filename: "2022-12-12-01-create-table-statuses.sql"}, events[0])
+	assert.Equal(t, EventFinished, events[1].Type)
+	assert.NoError(t, events[1].Err)
+	assert.Equal(t, Event{Type: EventStarted, Filename: "2022-12-12-02-create-table-news.sql"}, events[2])
+	assert.Equal(t, EventFailed, events[3].Type)
+	assert.Equal(t, errFailed, events[3].Err)
+
+	// nil observer
+	require.NoError(t, track(nil, "2022-12-12-01-create-table-statuses.sql", func() error { return nil }))
+}
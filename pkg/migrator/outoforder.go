@@ -0,0 +1,120 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Out-of-order policies, see Config.OutOfOrder.
+const (
+	OutOfOrderAllow = "allow"
+	OutOfOrderWarn  = "warn"
+	OutOfOrderDeny  = "deny"
+)
+
+// OutOfOrderMigration is a pending migration which sorts before the last applied migration.
+type OutOfOrderMigration struct {
+	Filename string `json:"filename"`
+	After    string `json:"after"`  // applied migration right before filename, empty if filename is the first
+	Before   string `json:"before"` // applied migration right after filename
+}
+
+// OutOfOrderError is returned by Run when OutOfOrder is deny and out-of-order migrations were found.
+type OutOfOrderError struct {
+	Migrations []OutOfOrderMigration
+}
+
+func (e *OutOfOrderError) Error() string {
+	ff := make([]string, 0, len(e.Migrations))
+	for _, o := range e.Migrations {
+		ff = append(ff, fmt.Sprintf(`"%s" (before "%s")`, o.Filename, o.Before))
+	}
+
+	return fmt.Sprintf("found %d out-of-order migrations: %s", len(e.Migrations), strings.Join(ff, ", "))
+}
+
+// OutOfOrder returns pending filenames which sort before the last applied migration, e.g. merged late from a long-lived branch.
+func (m *Migrator) OutOfOrder(ctx context.Context, pending []string) ([]OutOfOrderMigration, error) {
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil || !ok {
+		return nil, err
+	}
+
+	return m.outOfOrder(ctx, pending)
+}
+
+func (m *Migrator) outOfOrder(ctx context.Context, pending []string) ([]OutOfOrderMigration, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	var applied []string
+	if err := m.whereVersioned(m.db.ModelContext(ctx, (*PgMigration)(nil)).Column("filename")).Select(&applied); err != nil {
+		return nil, fmt.Errorf("fetch applied migrations failed: %w", err)
+	}
+
+	// skip applied migrations which do not match current file mask
+	versioned := applied[:0]
+	for _, filename := range applied {
+		if m.fileMask.MatchString(filename) {
+			versioned = append(versioned, filename)
+		}
+	}
+
+	return findOutOfOrder(pending, versioned), nil
+}
+
+// findOutOfOrder returns pending filenames which sort before the last applied filename with their position.
+func findOutOfOrder(pending, applied []string) []OutOfOrderMigration {
+	if len(applied) == 0 {
+		return nil
+	}
+
+	sort.Strings(applied)
+	last := applied[len(applied)-1]
+
+	var res []OutOfOrderMigration
+	for _, filename := range pending {
+		if filename >= last {
+			continue
+		}
+
+		i := sort.SearchStrings(applied, filename)
+		o := OutOfOrderMigration{Filename: filename, Before: applied[i]}
+		if i > 0 {
+			o.After = applied[i-1]
+		}
+
+		res = append(res, o)
+	}
+
+	return res
+}
+
+// checkOutOfOrder applies OutOfOrder policy to pending filenames.
+func (m *Migrator) checkOutOfOrder(ctx context.Context, pending []string) error {
+	policy := m.cfg.OutOfOrder
+	switch policy {
+	case "", OutOfOrderAllow:
+		return nil
+	case OutOfOrderWarn, OutOfOrderDeny:
+	default:
+		return fmt.Errorf(`invalid OutOfOrder "%s", use %s, %s or %s`, policy, OutOfOrderAllow, OutOfOrderWarn, OutOfOrderDeny)
+	}
+
+	oo, err := m.outOfOrder(ctx, pending)
+	if err != nil || len(oo) == 0 {
+		return err
+	} else if policy == OutOfOrderDeny {
+		return &OutOfOrderError{Migrations: oo}
+	}
+
+	for _, o := range oo {
+		log.Printf(`warning: migration %s is out of order, it sorts before applied %s`, o.Filename, o.Before)
+	}
+
+	return nil
+}
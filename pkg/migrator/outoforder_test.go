@@ -0,0 +1,66 @@
+package migrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindOutOfOrder(t *testing.T) {
+	applied := []string{
+		"2022-12-13-01-create-categories-table.sql",
+		"2022-12-12-01-create-table-statuses.sql",
+		"2022-12-13-02-create-tags-table.sql",
+	}
+	pending := []string{
+		"2022-12-11-01-first.sql",
+		"2022-12-12-02-create-table-news.sql",
+		"2022-12-14-01-new.sql",
+	}
+
+	got := findOutOfOrder(pending, applied)
+	assert.Equal(t, []OutOfOrderMigration{
+		{Filename: "2022-12-11-01-first.sql", Before: "2022-12-12-01-create-table-statuses.sql"},
+		{Filename: "2022-12-12-02-create-table-news.sql", After: "2022-12-12-01-create-table-statuses.sql", Before: "2022-12-13-01-create-categories-table.sql"},
+	}, got)
+
+	assert.Empty(t, findOutOfOrder(pending, nil))
+}
+
+func TestMigrator_checkOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+
+	err := recreateSchema()
+	require.NoError(t, err)
+
+	err = execRun(ctx, t)
+	require.NoError(t, err)
+
+	// emulate migration merged late from long-lived branch
+	filename := "2022-12-12-02-create-table-news.sql"
+	_, err = testMigrator.db.ModelContext(ctx, (*PgMigration)(nil)).Where(`"filename" = ?`, filename).Delete()
+	require.NoError(t, err)
+
+	oo, err := testMigrator.OutOfOrder(ctx, []string{filename})
+	require.NoError(t, err)
+	require.Len(t, oo, 1)
+	assert.Equal(t, "2022-12-12-01-create-table-statuses.sql", oo[0].After)
+
+	for _, policy := range []string{OutOfOrderAllow, OutOfOrderWarn} {
+		m := *testMigrator
+		m.cfg.OutOfOrder = policy
+		require.NoError(t, m.checkOutOfOrder(ctx, []string{filename}))
+	}
+
+	m := *testMigrator
+	m.cfg.OutOfOrder = OutOfOrderDeny
+	err = m.checkOutOfOrder(ctx, []string{filename})
+	var ooErr *OutOfOrderError
+	require.ErrorAs(t, err, &ooErr)
+	assert.Equal(t, oo, ooErr.Migrations)
+
+	m.cfg.OutOfOrder = "ignore"
+	require.EqualError(t, m.checkOutOfOrder(ctx, []string{filename}), `invalid OutOfOrder "ignore", use allow, warn or deny`)
+}
@@ -0,0 +1,90 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// RenamedMigration is an applied migration without local file, which has local file with the same md5sum.
+type RenamedMigration struct {
+	PgMigration
+	LocalFilename string `json:"localFilename"`
+}
+
+// findRenamed splits missing migrations into missing and likely renamed by md5sum of local files, which were not applied.
+func findRenamed(local, completed, missing []PgMigration) (res []PgMigration, renamed []RenamedMigration) {
+	completedMapping := make(map[string]struct{}, len(completed))
+	for _, c := range completed {
+		completedMapping[c.Filename] = struct{}{}
+	}
+
+	// md5sum -> not applied local filenames
+	pending := make(map[string][]string)
+	for _, l := range local {
+		if _, ok := completedMapping[l.Filename]; !ok {
+			pending[l.Md5sum] = append(pending[l.Md5sum], l.Filename)
+		}
+	}
+
+	for _, p := range missing {
+		if ff := pending[p.Md5sum]; len(ff) > 0 {
+			renamed = append(renamed, RenamedMigration{PgMigration: p, LocalFilename: ff[0]})
+			pending[p.Md5sum] = ff[1:]
+			continue
+		}
+
+		res = append(res, p)
+	}
+
+	return res, renamed
+}
+
+// Rename updates filename of applied migration after local file was renamed on purpose.
+// New file must exist in migrations directory and must not be applied.
+func (m *Migrator) Rename(ctx context.Context, oldFilename, newFilename string) (*PgMigration, error) {
+	filenames, err := m.readAllFiles()
+	if err != nil {
+		return nil, err
+	} else if !slices.Contains(filenames, newFilename) {
+		return nil, fmt.Errorf(`migration file "%s" was not found`, newFilename)
+	}
+
+	var pm PgMigration
+	err = m.withLock(ctx, func(lm *Migrator) error {
+		// check migration table, rename never creates it
+		if ok, err := lm.tableExists(ctx); err != nil {
+			return err
+		} else if !ok {
+			return errors.New("migrations table does not exist")
+		}
+
+		if cnt, err := lm.db.ModelContext(ctx, (*PgMigration)(nil)).Where(`"filename" = ?`, newFilename).Count(); err != nil {
+			return fmt.Errorf(`fetch migration "%s" failed: %w`, newFilename, err)
+		} else if cnt > 0 {
+			return fmt.Errorf(`migration "%s" is already applied`, newFilename)
+		}
+
+		if err := lm.selectModel(ctx, &pm).Where(`"filename" = ?`, oldFilename).Select(); err != nil {
+			if errors.Is(err, pg.ErrNoRows) {
+				return fmt.Errorf(`migration "%s" was not found in db`, oldFilename)
+			}
+			return fmt.Errorf(`fetch migration "%s" failed: %w`, oldFilename, err)
+		}
+
+		pm.Filename = newFilename
+		if _, err := lm.db.ModelContext(ctx, &pm).Column("filename").WherePK().Update(); err != nil {
+			return fmt.Errorf(`update filename migration failed: %w`, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pm, nil
+}
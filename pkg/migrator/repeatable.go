@@ -0,0 +1,214 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// repeatablePrefix returns filename prefix of repeatable migrations in migrations table.
+func (m *Migrator) repeatablePrefix() string {
+	return path.Clean(filepath.ToSlash(m.cfg.RepeatableDir)) + "/"
+}
+
+// whereVersioned excludes repeatable migrations from query.
+func (m *Migrator) whereVersioned(q *orm.Query) *orm.Query {
+	if m.cfg.RepeatableDir == "" {
+		return q
+	}
+
+	return q.Where(`strpos("filename", ?) <> 1`, m.repeatablePrefix())
+}
+
+// readRepeatableFiles read files from repeatable dir and return its filenames with repeatable dir prefix.
+func (m *Migrator) readRepeatableFiles() ([]string, error) {
+	if m.cfg.RepeatableDir == "" {
+		return nil, nil
+	}
+
+	files, err := fs.ReadDir(m.fsys, strings.TrimSuffix(m.repeatablePrefix(), "/"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read repeatable files failed: %w", err)
+	}
+
+	var filenames []string
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".sql") || strings.HasSuffix(f.Name(), "MANUAL.sql") || isDownFile(f.Name()) {
+			continue
+		}
+
+		filenames = append(filenames, m.repeatablePrefix()+f.Name())
+	}
+
+	sort.Strings(filenames)
+
+	return filenames, nil
+}
+
+// PlanRepeatable returns repeatable migrations which are new or changed since the last apply.
+func (m *Migrator) PlanRepeatable(ctx context.Context) ([]string, error) {
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
+		return nil, err
+	} else if !ok {
+		return m.readRepeatableFiles()
+	}
+
+	return m.planRepeatableFiles(ctx)
+}
+
+// planRepeatableFiles returns filenames of new or changed repeatable migrations.
+func (m *Migrator) planRepeatableFiles(ctx context.Context) ([]string, error) {
+	mm, err := m.planRepeatable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make([]string, 0, len(mm))
+	for _, mg := range mm {
+		filenames = append(filenames, mg.Filename)
+	}
+
+	return filenames, nil
+}
+
+func (m *Migrator) planRepeatable(ctx context.Context) (Migrations, error) {
+	filenames, err := m.readRepeatableFiles()
+	if err != nil || len(filenames) == 0 {
+		return nil, err
+	}
+
+	mm, err := m.newMigrations(filenames)
+	if err != nil {
+		return nil, fmt.Errorf("prepare repeatable migrations failed: %w", err)
+	}
+
+	// fetch applied repeatable migrations from db
+	var pm []PgMigration
+	if err = m.selectModel(ctx, &pm).Where(`"filename" in (?)`, pg.In(filenames)).Select(); err != nil {
+		return nil, fmt.Errorf("fetch repeatable migrations failed: %w", err)
+	}
+
+	applied := make(map[string]string, len(pm))
+	for _, p := range pm {
+		applied[p.Filename] = p.Md5sum
+	}
+
+	var res Migrations
+	for _, mg := range mm {
+		if sum, ok := applied[mg.Filename]; !ok || sum != mg.Md5Sum {
+			res = append(res, mg)
+		}
+	}
+
+	return res, nil
+}
+
+// LastRepeatable returns applied repeatable migrations.
+func (m *Migrator) LastRepeatable(ctx context.Context) ([]PgMigration, error) {
+	if m.cfg.RepeatableDir == "" {
+		return nil, nil
+	}
+
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, nil
+	}
+
+	var pm []PgMigration
+	if err := m.selectModel(ctx, &pm).Where(`strpos("filename", ?) = 1`, m.repeatablePrefix()).Order(`filename`).Select(); err != nil {
+		return nil, fmt.Errorf(`fetch repeatable migrations failed: %w`, err)
+	}
+
+	return pm, nil
+}
+
+// runRepeatable applies new and changed repeatable migrations if all versioned migrations are applied.
+func (m *Migrator) runRepeatable(ctx context.Context, obs Observer) error {
+	if m.cfg.RepeatableDir == "" {
+		return nil
+	}
+
+	if pending, err := m.plan(ctx); err != nil {
+		return err
+	} else if len(pending) > 0 {
+		return nil
+	}
+
+	mm, err := m.planRepeatable(ctx)
+	if err != nil {
+		return err
+	}
+
+	var notices []string
+	for _, mg := range mm {
+		if err = checkInterrupted(ctx); err != nil {
+			return err
+		}
+
+		notices, err = m.trackWithNotices(obs, mg.Filename, func() error {
+			return interrupted(ctx, mg, m.retryOnLockTimeout(ctx, mg, m.withHistory(m.applyRepeatableMigration)))
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", mg.Filename, err)
+		}
+
+		if err = m.saveNotices(ctx, mg.Filename, notices); err != nil {
+			return fmt.Errorf("%s: %w", mg.Filename, err)
+		}
+	}
+
+	return nil
+}
+
+// applyRepeatableMigration applies repeatable migration inside transaction and updates its md5sum.
+func (m *Migrator) applyRepeatableMigration(ctx context.Context, mg Migration) (err error) {
+	var tx *pg.Tx
+	tx, err = m.db.Begin()
+	if err != nil {
+		return fmt.Errorf(`begin transaction failed: %w`, err)
+	}
+
+	defer func() {
+		err = finishTxOnErr(tx, err)
+	}()
+
+	if err = m.setTimeouts(ctx, tx, mg); err != nil {
+		return err
+	}
+
+	// run
+	start := time.Now()
+	if _, err = tx.ExecContext(ctx, string(mg.Data)); err != nil {
+		return fmt.Errorf(`apply migration failed: %w`, err)
+	}
+
+	finish := time.Now()
+	pm := m.toDB(mg)
+	pm.StartedAt = start
+	pm.FinishedAt = &finish
+
+	_, err = tx.ModelContext(ctx, pm).
+		OnConflict(`("filename") DO UPDATE`).
+		Set(`"startedAt" = EXCLUDED."startedAt", "finishedAt" = EXCLUDED."finishedAt", "md5sum" = EXCLUDED."md5sum"`).
+		Set(`"dbUser" = EXCLUDED."dbUser", "osUser" = EXCLUDED."osUser", "hostname" = EXCLUDED."hostname", "version" = EXCLUDED."version", "revision" = EXCLUDED."revision"`).
+		Insert()
+	if err != nil {
+		return fmt.Errorf(`save repeatable migration "%s" failed: %w`, mg.Filename, err)
+	}
+
+	return nil
+}
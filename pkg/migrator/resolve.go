@@ -0,0 +1,142 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ResolveAction is a way to resolve unfinished non-transactional migration.
+type ResolveAction string
+
+const (
+	// ResolveDone marks unfinished migration as finished, use it if migration was applied manually.
+	ResolveDone ResolveAction = "done"
+
+	// ResolveRetry deletes unfinished migration from migrations table, so it will be applied again.
+	ResolveRetry ResolveAction = "retry"
+)
+
+// UnfinishedError is returned when migrations table contains non-transactional migrations
+// which were started but not finished: migration failed or was interrupted.
+type UnfinishedError struct {
+	Migrations []PgMigration
+}
+
+func (e *UnfinishedError) Error() string {
+	ff := make([]string, 0, len(e.Migrations))
+	for _, pm := range e.Migrations {
+		ff = append(ff, fmt.Sprintf(`"%s" (started at %s)`, pm.Filename, pm.StartedAt.Format(time.DateTime)))
+	}
+
+	return fmt.Sprintf("found %d unfinished migrations: %s", len(e.Migrations), strings.Join(ff, ", "))
+}
+
+// Status is a summary of migrations table and migration files.
+type Status struct {
+	Initialized bool          `json:"initialized"` // migrations table exists
+	Applied     int           `json:"applied"`
+	Pending     []string      `json:"pending"`
+	Unfinished  []PgMigration `json:"unfinished"`
+}
+
+// Status returns count of applied migrations, pending migrations and unfinished non-transactional migrations.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	var (
+		st  Status
+		err error
+	)
+
+	// check migration table, read-only methods never create it
+	if st.Initialized, err = m.tableExists(ctx); err != nil {
+		return nil, err
+	} else if !st.Initialized {
+		st.Pending, err = m.readAllFiles()
+		return &st, err
+	}
+
+	if st.Applied, err = m.db.ModelContext(ctx, (*PgMigration)(nil)).Count(); err != nil {
+		return nil, fmt.Errorf("count applied migrations failed: %w", err)
+	}
+
+	if st.Unfinished, err = m.unfinished(ctx); err != nil {
+		return nil, err
+	}
+
+	if st.Pending, err = m.plan(ctx); err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+// unfinished returns migrations with empty finishedAt.
+func (m *Migrator) unfinished(ctx context.Context) ([]PgMigration, error) {
+	var pm []PgMigration
+	if err := m.selectModel(ctx, &pm).Where(`"finishedAt" is null`).Order(`id`).Select(); err != nil {
+		return nil, fmt.Errorf("fetch unfinished migrations failed: %w", err)
+	}
+
+	return pm, nil
+}
+
+// checkUnfinished returns UnfinishedError if unfinished migrations were found.
+func (m *Migrator) checkUnfinished(ctx context.Context) error {
+	pm, err := m.unfinished(ctx)
+	if err != nil {
+		return err
+	} else if len(pm) > 0 {
+		return &UnfinishedError{Migrations: pm}
+	}
+
+	return nil
+}
+
+// Resolve resolves unfinished non-transactional migration by filename.
+// ResolveDone sets finishedAt to now, ResolveRetry deletes migration from migrations table.
+func (m *Migrator) Resolve(ctx context.Context, filename string, action ResolveAction) (*PgMigration, error) {
+	if action != ResolveDone && action != ResolveRetry {
+		return nil, fmt.Errorf(`unknown resolve action "%s"`, action)
+	}
+
+	var pm PgMigration
+	err := m.withLock(ctx, func(lm *Migrator) error {
+		// create migration table if not exists
+		if err := lm.createMigratorTable(ctx); err != nil {
+			return err
+		}
+
+		if err := lm.selectModel(ctx, &pm).Where(`"filename" = ?`, filename).Select(); err != nil {
+			if errors.Is(err, pg.ErrNoRows) {
+				return fmt.Errorf(`migration "%s" was not found in db`, filename)
+			}
+			return fmt.Errorf(`fetch migration "%s" failed: %w`, filename, err)
+		} else if pm.FinishedAt != nil {
+			return fmt.Errorf(`migration "%s" is already finished at %s`, filename, pm.FinishedAt.Format(time.DateTime))
+		}
+
+		if action == ResolveRetry {
+			if _, err := lm.db.ModelContext(ctx, &pm).WherePK().Delete(); err != nil {
+				return fmt.Errorf(`delete "%s" from db failed: %w`, filename, err)
+			}
+			return nil
+		}
+
+		now := time.Now()
+		pm.FinishedAt = &now
+		if _, err := lm.db.ModelContext(ctx, &pm).Column("finishedAt").WherePK().Update(); err != nil {
+			return fmt.Errorf(`update finishedAt migration failed: %w`, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pm, nil
+}
@@ -0,0 +1,92 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// lockNotAvailable is SQLSTATE of error returned when lock_timeout is reached.
+const lockNotAvailable = "55P03"
+
+// retryPolicy is a retry policy with exponential backoff, see lockRetryPolicy and connectRetryPolicy.
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+}
+
+// lockRetryPolicy returns retry policy from config.
+func (m *Migrator) lockRetryPolicy() (retryPolicy, error) {
+	p := retryPolicy{attempts: max(m.cfg.LockRetryAttempts, 1), jitter: m.cfg.LockRetryJitter}
+
+	var err error
+	if m.cfg.LockRetryBackoff != "" {
+		if p.backoff, err = time.ParseDuration(m.cfg.LockRetryBackoff); err != nil {
+			return p, fmt.Errorf("invalid LockRetryBackoff: %w", err)
+		}
+	}
+
+	if m.cfg.LockRetryMaxBackoff != "" {
+		if p.maxBackoff, err = time.ParseDuration(m.cfg.LockRetryMaxBackoff); err != nil {
+			return p, fmt.Errorf("invalid LockRetryMaxBackoff: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// delay returns exponential backoff with random jitter before next attempt, attempt starts from 1.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && (p.maxBackoff == 0 || d < p.maxBackoff); i++ {
+		d *= 2
+	}
+
+	if p.maxBackoff > 0 && d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+
+	if p.jitter > 0 && d > 0 {
+		d += time.Duration(rand.Float64() * p.jitter * float64(d))
+	}
+
+	return d
+}
+
+// isLockNotAvailable reports whether err is lock_timeout error.
+func isLockNotAvailable(err error) bool {
+	var pgErr pg.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == lockNotAvailable
+}
+
+// retryOnLockTimeout runs apply until it succeeds or fails with error other than lock_timeout.
+// Each failed attempt is logged.
+func (m *Migrator) retryOnLockTimeout(ctx context.Context, mg Migration, apply func(context.Context, Migration) error) error {
+	p, err := m.lockRetryPolicy()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = apply(ctx, mg)
+		if err == nil || !isLockNotAvailable(err) || attempt >= p.attempts {
+			return err
+		}
+
+		d := p.delay(attempt)
+		log.Printf("%s: lock not available (attempt %d/%d), retry in %v", mg.Filename, attempt, p.attempts, d.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
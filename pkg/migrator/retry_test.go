@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPgError struct {
+	code string
+}
+
+func (e testPgError) Error() string            { return "ERROR #" + e.code }
+func (e testPgError) Field(f byte) string      { return map[byte]string{'C': e.code}[f] }
+func (e testPgError) IntegrityViolation() bool { return false }
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := retryPolicy{backoff: time.Second, maxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.delay(1))
+	assert.Equal(t, 2*time.Second, p.delay(2))
+	assert.Equal(t, 4*time.Second, p.delay(3))
+	assert.Equal(t, 5*time.Second, p.delay(4))
+	assert.Equal(t, 5*time.Second, p.delay(100))
+
+	p.jitter = 0.5
+	for range 10 {
+		d := p.delay(2)
+		assert.GreaterOrEqual(t, d, 2*time.Second)
+		assert.Less(t, d, 3*time.Second)
+	}
+}
+
+func TestIsLockNotAvailable(t *testing.T) {
+	assert.True(t, isLockNotAvailable(testPgError{code: lockNotAvailable}))
+	assert.True(t, isLockNotAvailable(fmt.Errorf("apply migration failed: %w", testPgError{code: lockNotAvailable})))
+	assert.False(t, isLockNotAvailable(testPgError{code: "57014"}))
+	assert.False(t, isLockNotAvailable(errors.New("55P03")))
+}
+
+func TestMigrator_retryOnLockTimeout(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.LockRetryBackoff = "1ms"
+	m := NewMigrator(nil, cfg, "testdata")
+	mg := Migration{Filename: "2022-12-12-01-create-table-news.sql"}
+
+	t.Run("retry until success", func(t *testing.T) {
+		var calls int
+		err := m.retryOnLockTimeout(context.Background(), mg, func(context.Context, Migration) error {
+			if calls++; calls < 3 {
+				return testPgError{code: lockNotAvailable}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		var calls int
+		err := m.retryOnLockTimeout(context.Background(), mg, func(context.Context, Migration) error {
+			calls++
+			return testPgError{code: lockNotAvailable}
+		})
+		assert.True(t, isLockNotAvailable(err))
+		assert.Equal(t, cfg.LockRetryAttempts, calls)
+	})
+
+	t.Run("no retry on other errors", func(t *testing.T) {
+		var calls int
+		err := m.retryOnLockTimeout(context.Background(), mg, func(context.Context, Migration) error {
+			calls++
+			return testPgError{code: "42601"}
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,172 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// downSuffix is a suffix of undo file, e.g. 2022-12-13-01-create-categories-table.down.sql.
+const downSuffix = ".down.sql"
+
+// DownMigration is an applied migration with its undo file.
+type DownMigration struct {
+	PgMigration
+	DownFilename string `json:"downFilename"` // empty if undo file was not found
+}
+
+// isDownFile reports whether filename is undo file.
+func isDownFile(filename string) bool {
+	return strings.HasSuffix(filename, downSuffix)
+}
+
+// downFilename returns undo filename for migration filename.
+func downFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".sql") + downSuffix
+}
+
+// RollbackPlan returns last n applied migrations in rollback order with their undo files.
+func (m *Migrator) RollbackPlan(ctx context.Context, n int) ([]DownMigration, error) {
+	if err := checkRollbackCount(n); err != nil {
+		return nil, err
+	}
+
+	// check migration table, read-only methods never create it
+	if ok, err := m.tableExists(ctx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, nil
+	}
+
+	return m.rollbackPlan(ctx, n)
+}
+
+// checkRollbackCount returns error if n is not positive, Limit(0) of go-pg selects all migrations.
+func checkRollbackCount(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid count %d, it must be positive", n)
+	}
+
+	return nil
+}
+
+func (m *Migrator) rollbackPlan(ctx context.Context, n int) ([]DownMigration, error) {
+	// fetch last migrations
+	var pm []PgMigration
+	if err := m.whereVersioned(m.selectModel(ctx, &pm)).Order(`id DESC`).Limit(n).Select(); err != nil {
+		return nil, fmt.Errorf(`fetch last %d migrations failed: %w`, n, err)
+	}
+
+	dm := make([]DownMigration, 0, len(pm))
+	for _, p := range pm {
+		d := DownMigration{PgMigration: p}
+		filename := downFilename(p.Filename)
+		if _, err := fs.Stat(m.fsys, filename); err == nil {
+			d.DownFilename = filename
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf(`find undo file "%s" failed: %w`, filename, err)
+		}
+
+		dm = append(dm, d)
+	}
+
+	return dm, nil
+}
+
+// Rollback reverts last n applied migrations in reverse order. Each undo file runs inside transaction
+// and deletes migration from migrations table. Nothing is reverted if any undo file is missing.
+func (m *Migrator) Rollback(ctx context.Context, n int, obs Observer) ([]DownMigration, error) {
+	if err := checkRollbackCount(n); err != nil {
+		return nil, err
+	}
+
+	var dm []DownMigration
+	err := m.withLock(ctx, func(lm *Migrator) error {
+		// create migration table if not exists
+		if err := lm.createMigratorTable(ctx); err != nil {
+			return err
+		}
+
+		// check failed non-transactional migrations
+		if err := lm.checkUnfinished(ctx); err != nil {
+			return err
+		}
+
+		var err error
+		if dm, err = lm.rollbackPlan(ctx, n); err != nil {
+			return err
+		} else if len(dm) == 0 {
+			return errors.New(`applied migrations were not found`)
+		}
+
+		// check undo files
+		var missing []string
+		for _, d := range dm {
+			if d.DownFilename == "" {
+				missing = append(missing, d.Filename)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf(`undo files were not found for migrations: %s`, strings.Join(missing, ", "))
+		}
+
+		// revert migrations
+		for _, d := range dm {
+			if err = checkInterrupted(ctx); err != nil {
+				return err
+			}
+
+			_, err = lm.trackWithNotices(obs, d.DownFilename, func() error {
+				mg, err := NewMigrationFS(lm.fsys, d.DownFilename)
+				if err != nil {
+					return fmt.Errorf("open failed: %w", err)
+				}
+
+				// undo file is applied inside transaction
+				mg.Transactional = true
+				return interrupted(ctx, mg, lm.withHistory(func(ctx context.Context, mg Migration) error {
+					return lm.applyDownMigration(ctx, mg, d)
+				})(ctx, mg))
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.DownFilename, err)
+			}
+		}
+
+		return nil
+	})
+
+	return dm, err
+}
+
+// applyDownMigration runs undo file mg of migration d and deletes migration from migrations table inside transaction.
+func (m *Migrator) applyDownMigration(ctx context.Context, mg Migration, d DownMigration) (err error) {
+	var tx *pg.Tx
+	tx, err = m.db.Begin()
+	if err != nil {
+		return fmt.Errorf(`begin transaction failed: %w`, err)
+	}
+
+	defer func() {
+		err = finishTxOnErr(tx, err)
+	}()
+
+	if err = m.setTimeouts(ctx, tx, mg); err != nil {
+		return err
+	}
+
+	// run
+	if _, err = tx.ExecContext(ctx, string(mg.Data)); err != nil {
+		return fmt.Errorf(`apply undo migration failed: %w`, err)
+	}
+
+	if _, err = tx.ModelContext(ctx, &d.PgMigration).WherePK().Delete(); err != nil {
+		return fmt.Errorf(`delete "%s" from db failed: %w`, d.Filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration filename suffixes.
+const (
+	SuffixNonTransactional = "NONTR"
+	SuffixManual           = "MANUAL"
+)
+
+var (
+	// reDescriptionChars matches chars which are replaced by dash in migration description.
+	reDescriptionChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// reFilenamePrefix matches date and optional sequence of migration filename, e.g. 2022-12-13-01-.
+	reFilenamePrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-(\d{2}-)?`)
+)
+
+// NewMigrationFile creates migration file with today's date, next sequence number and description,
+// e.g. 2022-12-13-02-create-tags-table.sql. Suffix is empty, SuffixNonTransactional or SuffixManual.
+// It returns filename of created file.
+func (m *Migrator) NewMigrationFile(description, suffix string) (string, error) {
+	if m.rootDir == "" {
+		return "", errors.New("migrations directory is not set, migrator was created from fs.FS")
+	}
+
+	filename, err := m.newFilename(description, suffix, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(filepath.Join(m.rootDir, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("create file failed: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.WriteString(migrationTemplate(filename, suffix)); err != nil {
+		return "", fmt.Errorf("write file %s failed: %w", filename, err)
+	}
+
+	return filename, f.Close()
+}
+
+// newFilename returns next migration filename for date. It checks that filename matches FileMask
+// and migration with the same description does not exist.
+func (m *Migrator) newFilename(description, suffix string, date time.Time) (string, error) {
+	if suffix != "" && suffix != SuffixNonTransactional && suffix != SuffixManual {
+		return "", fmt.Errorf(`unknown suffix "%s"`, suffix)
+	}
+
+	desc := strings.Trim(reDescriptionChars.ReplaceAllString(strings.ToLower(description), "-"), "-")
+	if desc == "" {
+		return "", errors.New("description is empty, use latin letters and digits")
+	}
+
+	files, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return "", fmt.Errorf("read files failed: %w", err)
+	}
+
+	prefix, seq := date.Format(time.DateOnly)+"-", 0
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		if migrationDescription(f.Name()) == desc {
+			return "", fmt.Errorf(`migration "%s" already exists: %s`, desc, f.Name())
+		}
+
+		// find max sequence number for date
+		if rest, ok := strings.CutPrefix(f.Name(), prefix); ok && len(rest) > 3 && rest[2] == '-' {
+			if n, err := strconv.Atoi(rest[:2]); err == nil && n > seq {
+				seq = n
+			}
+		}
+	}
+
+	if seq >= 99 {
+		return "", fmt.Errorf("too many migrations for %s", date.Format(time.DateOnly))
+	}
+
+	filename := fmt.Sprintf("%s%02d-%s", prefix, seq+1, desc)
+	if suffix != "" {
+		filename += "-" + suffix
+	}
+	filename += ".sql"
+
+	if !m.fileMask.MatchString(filename) {
+		return "", fmt.Errorf(`filename %s does not match FileMask "%s"`, filename, m.cfg.FileMask)
+	}
+
+	return filename, nil
+}
+
+// migrationDescription returns description of migration filename without date, sequence and suffixes.
+func migrationDescription(filename string) string {
+	if !reFilenamePrefix.MatchString(filename) || !strings.HasSuffix(filename, ".sql") || isDownFile(filename) {
+		return ""
+	}
+
+	desc := strings.TrimSuffix(reFilenamePrefix.ReplaceAllString(filename, ""), ".sql")
+	desc = strings.TrimSuffix(desc, "-"+SuffixNonTransactional)
+	desc = strings.TrimSuffix(desc, "-"+SuffixManual)
+
+	return desc
+}
+
+// migrationTemplate returns content of new migration file.
+func migrationTemplate(filename, suffix string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- %s\n", filename)
+
+	switch suffix {
+	case SuffixNonTransactional:
+		sb.WriteString("-- Runs outside transaction, statements are executed one by one.\n")
+	case SuffixManual:
+		sb.WriteString("-- Manual migration, it is ignored by pgmigrator.\n")
+	}
+
+	sb.WriteString("-- Optional directives line: \"-- pgmigrator: statement_timeout=30s lock_timeout=3s tags=example\".\n\n")
+
+	return sb.String()
+}
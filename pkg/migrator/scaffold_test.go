@@ -0,0 +1,76 @@
+package migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_newFilename(t *testing.T) {
+	m := NewMigrator(nil, NewDefaultConfig(), "testdata")
+	date := time.Date(2022, 12, 13, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		suffix      string
+		want        string
+		wantErr     string
+	}{
+		{description: "Add users table", want: "2022-12-13-04-add-users-table.sql"},
+		{description: "add_users index", suffix: SuffixNonTransactional, want: "2022-12-13-04-add-users-index-NONTR.sql"},
+		{description: "fix data", suffix: SuffixManual, want: "2022-12-13-04-fix-data-MANUAL.sql"},
+		{description: "create-tags-table", wantErr: `migration "create-tags-table" already exists: 2022-12-13-02-create-tags-table.sql`},
+		{description: "add comments news", wantErr: `migration "add-comments-news" already exists: 2022-12-12-03-add-comments-news-NONTR.sql`},
+		{description: "!!!", wantErr: "description is empty, use latin letters and digits"},
+		{description: "test", suffix: "UP", wantErr: `unknown suffix "UP"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			got, err := m.newFilename(tt.description, tt.suffix, date)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("first migration for date", func(t *testing.T) {
+		got, err := m.newFilename("add users table", "", date.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, "2022-12-14-01-add-users-table.sql", got)
+	})
+
+	t.Run("file mask", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.FileMask = `\d{4}-\d{2}-\d{2}-\d{2}-[a-z]+.sql`
+		mm := NewMigrator(nil, cfg, "testdata")
+		_, err := mm.newFilename("add users table", "", date)
+		assert.EqualError(t, err, `filename 2022-12-13-04-add-users-table.sql does not match FileMask "\d{4}-\d{2}-\d{2}-\d{2}-[a-z]+.sql"`)
+	})
+}
+
+func TestMigrator_NewMigrationFile(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMigrator(nil, NewDefaultConfig(), dir)
+
+	filename, err := m.NewMigrationFile("create index", SuffixNonTransactional)
+	require.NoError(t, err)
+	assert.Equal(t, time.Now().Format(time.DateOnly)+"-01-create-index-NONTR.sql", filename)
+
+	// created file is valid migration
+	mg, err := NewMigration(dir, filename)
+	require.NoError(t, err)
+	assert.False(t, mg.Transactional)
+	assert.Empty(t, mg.Directives())
+
+	files, err := m.readAllFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filename}, files)
+
+	_, err = m.NewMigrationFile("create index", "")
+	require.Error(t, err)
+}
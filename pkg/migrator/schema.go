@@ -0,0 +1,310 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// schemaTableQueries create schema table, it stores applied schema versions of migrations table, one row per version.
+// Migrations table comment is not used for it, so comments of DBA are kept.
+var schemaTableQueries = []string{`
+	create table if not exists ?schemaTable
+		(
+			version       int                       not null,
+			description   text                      not null,
+			"appliedAt"   timestamptz default now() not null,
+			"dbUser"      text        default current_user,
+			primary key ("version")
+		)`,
+	`comment on table ?schemaTable is 'pgmigrator schema versions of migrations table'`,
+}
+
+// schemaStep is an incremental change of migrations table schema.
+// Columns are added as nullable text, then queries are executed. All statements must be idempotent,
+// ? is replaced by migrations table.
+type schemaStep struct {
+	version     int
+	description string
+	columns     []string
+	queries     []string
+}
+
+// schemaSteps are changes of migrations table schema in order of versions.
+// Released steps must not be changed, new step is appended with next version.
+var schemaSteps = []schemaStep{
+	{
+		version:     1,
+		description: "create migrations table",
+		queries: []string{`
+			create table if not exists ?
+				(
+					id            serial                    not null,
+					filename      text                      not null,
+					"startedAt"   timestamptz default now() not null,
+					"finishedAt"  timestamptz,
+					transactional bool        default true  not null,
+					md5sum        varchar(32)               not null,
+					primary key ("id"),
+					unique ("filename")
+				)`,
+		},
+	},
+	{
+		version:     2,
+		description: "add audit columns",
+		columns:     auditColumns,
+		queries: []string{
+			// default is set after column is added, so existing migrations are not attributed to current role
+			`alter table ? alter column "dbUser" set default current_user`,
+		},
+	},
+	{
+		version:     3,
+		description: "add notices column",
+		columns:     []string{"notices"},
+	},
+	{
+		version:     4,
+		description: "add column comments",
+		queries: []string{
+			`comment on column ?."filename" is 'migration filename'`,
+			`comment on column ?."startedAt" is 'start time of migration'`,
+			`comment on column ?."finishedAt" is 'finish time of migration, null if non-transactional migration was not finished'`,
+			`comment on column ?."transactional" is 'false if migration was applied without transaction'`,
+			`comment on column ?."md5sum" is 'md5 checksum of migration file'`,
+			`comment on column ?."dbUser" is 'database role which applied migration'`,
+			`comment on column ?."osUser" is 'operating system user which applied migration'`,
+			`comment on column ?."hostname" is 'host which applied migration'`,
+			`comment on column ?."version" is 'pgmigrator version'`,
+			`comment on column ?."revision" is 'git commit or CI job id, see --revision'`,
+			`comment on column ?."notices" is 'PostgreSQL notices of migration, see StoreNotices'`,
+		},
+	},
+	{
+		version:     5,
+		description: "create history table",
+		queries: []string{`
+			create table if not exists ?historyTable
+				(
+					id            serial                    not null,
+					filename      text                      not null,
+					status        text                      not null,
+					"startedAt"   timestamptz               not null,
+					"durationMs"  bigint                    not null,
+					transactional bool                      not null,
+					md5sum        varchar(32)               not null,
+					"sqlState"    text,
+					error         text,
+					detail        text,
+					hint          text,
+					position      int,
+					statement     int,
+					"dbUser"      text        default current_user,
+					"osUser"      text,
+					hostname      text,
+					version       text,
+					revision      text,
+					primary key ("id")
+				)`,
+			`comment on table ?historyTable is 'pgmigrator history of all attempts to apply migrations'`,
+		},
+	},
+}
+
+// SchemaVersion is the latest version of migrations table schema.
+var SchemaVersion = schemaSteps[len(schemaSteps)-1].version
+
+// SchemaTable returns name of schema table for migrations table.
+func SchemaTable(table string) string {
+	return table + "Schema"
+}
+
+// SchemaChange is a change of migrations table schema from previous version.
+type SchemaChange struct {
+	Version     int      `json:"version"`
+	Description string   `json:"description"`
+	Queries     []string `json:"queries"`
+}
+
+// SchemaPlan returns current schema version of migrations table and changes which upgrade it to SchemaVersion.
+// Version is 0 if table does not exist. Each change records its version to schema table.
+func (m *Migrator) SchemaPlan(ctx context.Context) (int, []SchemaChange, error) {
+	version, tracked, err := m.schemaVersion(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	table := pg.Ident(m.cfg.Table)
+	format := func(query string, params ...any) string {
+		return string(m.db.Formatter().FormatQuery(nil, query, params...))
+	}
+	record := func(version int, description string) string {
+		return format(`insert into ?schemaTable ("version", "description") values (?, ?) on conflict do nothing`, version, description)
+	}
+
+	var changes []SchemaChange
+	for _, s := range schemaSteps {
+		if s.version <= version {
+			continue
+		}
+
+		ch := SchemaChange{Version: s.version, Description: s.description}
+		for _, c := range s.columns {
+			ch.Queries = append(ch.Queries, format(`alter table ? add column if not exists ? text`, table, pg.Ident(c)))
+		}
+		for _, q := range s.queries {
+			ch.Queries = append(ch.Queries, format(q, table))
+		}
+		ch.Queries = append(ch.Queries, record(s.version, s.description))
+
+		changes = append(changes, ch)
+	}
+
+	if tracked {
+		return version, changes, nil
+	}
+
+	// schema table is created by the first change, detected version of up to date table is recorded
+	var queries []string
+	for _, q := range schemaTableQueries {
+		queries = append(queries, format(q))
+	}
+
+	if len(changes) == 0 {
+		changes = []SchemaChange{{Version: version, Description: "create schema table", Queries: append(queries, record(version, "detected by columns"))}}
+	} else {
+		changes[0].Queries = append(queries, changes[0].Queries...)
+	}
+
+	return version, changes, nil
+}
+
+// UpgradeTable creates migrations table or upgrades its schema to SchemaVersion under migrator lock.
+// It returns applied changes, see SchemaPlan.
+func (m *Migrator) UpgradeTable(ctx context.Context) ([]SchemaChange, error) {
+	var changes []SchemaChange
+	err := m.withLock(ctx, func(lm *Migrator) error {
+		var err error
+		changes, err = lm.upgradeTable(ctx)
+		return err
+	})
+
+	return changes, err
+}
+
+// createMigratorTable creates migration table if not exists and upgrades its schema.
+func (m *Migrator) createMigratorTable(ctx context.Context) error {
+	_, err := m.upgradeTable(ctx)
+	return err
+}
+
+// upgradeTable applies schema changes of migrations table, each change in its own transaction.
+// Version is checked first, so table is not altered if it is up to date.
+func (m *Migrator) upgradeTable(ctx context.Context) ([]SchemaChange, error) {
+	_, changes, err := m.SchemaPlan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ch := range changes {
+		if err = m.applySchemaChange(ctx, ch); err != nil {
+			return changes[:i], err
+		}
+	}
+
+	return changes, nil
+}
+
+// applySchemaChange executes queries of schema change in transaction.
+func (m *Migrator) applySchemaChange(ctx context.Context, ch SchemaChange) (err error) {
+	var tx *pg.Tx
+	tx, err = m.db.Begin()
+	if err != nil {
+		return fmt.Errorf(`begin transaction failed: %w`, err)
+	}
+
+	defer func() {
+		err = finishTxOnErr(tx, err)
+	}()
+
+	for _, q := range ch.Queries {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("upgrade migration table to version %d (%s) failed: %w", ch.Version, ch.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns schema version of migrations table from schema table, 0 if table does not exist.
+// Tables created before schema table have no versions, their version is detected by columns and tracked is false.
+func (m *Migrator) schemaVersion(ctx context.Context) (version int, tracked bool, err error) {
+	table := string(m.db.Formatter().FormatQuery(nil, "?", pg.Ident(SchemaTable(m.cfg.Table))))
+
+	if _, err = m.db.QueryOneContext(ctx, pg.Scan(&tracked), `select to_regclass(?) is not null`, table); err != nil {
+		return 0, false, fmt.Errorf("check schema table failed: %w", err)
+	}
+
+	// schema table may be left after migrations table was dropped, all steps are applied again then
+	exists, err := m.tableExists(ctx)
+	if err != nil || !exists {
+		return 0, tracked, err
+	}
+
+	if tracked {
+		if _, err = m.db.QueryOneContext(ctx, pg.Scan(&version), `select coalesce(max("version"), 0) from ?schemaTable`); err != nil {
+			return 0, false, fmt.Errorf("fetch migration table version failed: %w", err)
+		}
+		return version, true, nil
+	}
+
+	columns, err := m.tableColumns(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return detectSchemaVersion(columns), false, nil
+}
+
+// detectSchemaVersion returns the last schema version which columns of table were added by.
+// Versions without columns can not be detected, they are applied again, because all statements are idempotent.
+func detectSchemaVersion(columns map[string]bool) int {
+	version := 1
+	for _, s := range schemaSteps[1:] {
+		if len(s.columns) == 0 {
+			break
+		}
+
+		for _, c := range s.columns {
+			if !columns[c] {
+				return version
+			}
+		}
+		version = s.version
+	}
+
+	return version
+}
+
+// tableColumns returns column names of migrations table.
+func (m *Migrator) tableColumns(ctx context.Context) (map[string]bool, error) {
+	table := string(m.db.Formatter().FormatQuery(nil, "?", pg.Ident(m.cfg.Table)))
+
+	var names []string
+	_, err := m.db.QueryOneContext(ctx, pg.Scan(pg.Array(&names)), `
+		select array_agg(attname::text) from pg_attribute
+		where attrelid = ?::regclass and attnum > 0 and not attisdropped
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("fetch migration table columns failed: %w", err)
+	}
+
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[name] = true
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,36 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectSchemaVersion(t *testing.T) {
+	base := map[string]bool{"id": true, "filename": true, "startedAt": true, "finishedAt": true, "transactional": true, "md5sum": true}
+	with := func(columns ...string) map[string]bool {
+		res := make(map[string]bool, len(base)+len(columns))
+		for c := range base {
+			res[c] = true
+		}
+		for _, c := range columns {
+			res[c] = true
+		}
+		return res
+	}
+
+	assert.Equal(t, 1, detectSchemaVersion(base))
+	assert.Equal(t, 1, detectSchemaVersion(with("dbUser", "osUser")))
+	assert.Equal(t, 1, detectSchemaVersion(with("notices")))
+	assert.Equal(t, 2, detectSchemaVersion(with(auditColumns...)))
+	assert.Equal(t, 3, detectSchemaVersion(with(append(auditColumns, "notices")...)))
+}
+
+func TestSchemaSteps(t *testing.T) {
+	for i, s := range schemaSteps {
+		assert.Equal(t, i+1, s.version)
+		assert.NotEmpty(t, s.description)
+	}
+	assert.Equal(t, len(schemaSteps), SchemaVersion)
+	assert.Equal(t, "public.pgMigrationsSchema", SchemaTable(NewDefaultConfig().Table))
+}
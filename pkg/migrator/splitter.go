@@ -0,0 +1,151 @@
+package migrator
+
+import (
+	"strings"
+)
+
+// splitStatements splits sql script into separate statements by semicolons.
+// Semicolons inside string literals (including E'...' strings), quoted identifiers,
+// dollar-quoted strings and comments are ignored.
+// Statements without sql code (empty or comments only) are skipped.
+func splitStatements(sql string) []string {
+	var (
+		res     []string
+		start   int
+		hasCode bool
+	)
+
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '-' && peek(sql, i+1) == '-':
+			i = skipLineComment(sql, i)
+		case c == '/' && peek(sql, i+1) == '*':
+			i = skipBlockComment(sql, i)
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'', isEscapeString(sql, i))
+			hasCode = true
+		case c == '"':
+			i = skipQuoted(sql, i, '"', false)
+			hasCode = true
+		case c == '$':
+			if tag, ok := dollarTag(sql, i); ok {
+				i = skipDollarQuoted(sql, i, tag)
+			}
+			hasCode = true
+		case c == ';':
+			if hasCode {
+				res = append(res, strings.TrimSpace(sql[start:i]))
+			}
+			start, hasCode = i+1, false
+		case !isSpace(c):
+			hasCode = true
+		}
+	}
+
+	if hasCode {
+		res = append(res, strings.TrimSpace(sql[start:]))
+	}
+
+	return res
+}
+
+func peek(sql string, i int) byte {
+	if i < len(sql) {
+		return sql[i]
+	}
+
+	return 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// skipLineComment returns index of the last char of -- comment.
+func skipLineComment(sql string, i int) int {
+	if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+		return i + n
+	}
+
+	return len(sql) - 1
+}
+
+// skipBlockComment returns index of the last char of /* */ comment, nested comments are supported.
+func skipBlockComment(sql string, i int) int {
+	depth := 0
+	for ; i < len(sql); i++ {
+		switch {
+		case sql[i] == '/' && peek(sql, i+1) == '*':
+			depth++
+			i++
+		case sql[i] == '*' && peek(sql, i+1) == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(sql) - 1
+}
+
+// isEscapeString reports whether quote at i starts E'...' string with backslash escapes.
+func isEscapeString(sql string, i int) bool {
+	if i == 0 || sql[i-1] != 'E' && sql[i-1] != 'e' {
+		return false
+	}
+
+	return i == 1 || !isIdentChar(sql[i-2])
+}
+
+// skipQuoted returns index of the closing quote. Doubled quotes are treated as escaped quote,
+// backslash escapes are supported for E'...' strings.
+func skipQuoted(sql string, i int, quote byte, backslash bool) int {
+	for i++; i < len(sql); i++ {
+		switch {
+		case backslash && sql[i] == '\\':
+			i++
+		case sql[i] == quote && peek(sql, i+1) == quote:
+			i++
+		case sql[i] == quote:
+			return i
+		}
+	}
+
+	return len(sql) - 1
+}
+
+// dollarTag returns dollar quote tag ($$ or $tag$) starting at i.
+func dollarTag(sql string, i int) (string, bool) {
+	// $ inside identifier or positional parameter $1
+	if i > 0 && isIdentChar(sql[i-1]) {
+		return "", false
+	}
+
+	for j := i + 1; j < len(sql); j++ {
+		c := sql[j]
+		switch {
+		case c == '$':
+			return sql[i : j+1], true
+		case c >= '0' && c <= '9' && j == i+1, !isIdentChar(c):
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// skipDollarQuoted returns index of the last char of closing dollar quote tag.
+func skipDollarQuoted(sql string, i int, tag string) int {
+	start := i + len(tag)
+	if n := strings.Index(sql[start:], tag); n >= 0 {
+		return start + n + len(tag) - 1
+	}
+
+	return len(sql) - 1
+}
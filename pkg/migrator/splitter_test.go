@@ -0,0 +1,75 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "empty",
+			sql:  " \n-- only comment\n/* block; */ ;;",
+			want: nil,
+		},
+		{
+			name: "two statements",
+			sql: `CREATE INDEX CONCURRENTLY "news_title_idx" ON "news" ("title");
+CREATE INDEX CONCURRENTLY "news_alias_idx" ON "news" ("alias");
+`,
+			want: []string{
+				`CREATE INDEX CONCURRENTLY "news_title_idx" ON "news" ("title")`,
+				`CREATE INDEX CONCURRENTLY "news_alias_idx" ON "news" ("alias")`,
+			},
+		},
+		{
+			name: "without trailing semicolon",
+			sql:  "select 1; select 2",
+			want: []string{"select 1", "select 2"},
+		},
+		{
+			name: "string literals",
+			sql:  `select 'a;b', 'it''s;'; select E'\';', e'\\'; select ';'`,
+			want: []string{`select 'a;b', 'it''s;'`, `select E'\';', e'\\'`, `select ';'`},
+		},
+		{
+			name: "not escape string",
+			sql:  `select name'\'; select 2`,
+			want: []string{`select name'\'`, `select 2`},
+		},
+		{
+			name: "quoted identifiers",
+			sql:  `select 1 as "a;""b"; select 2`,
+			want: []string{`select 1 as "a;""b"`, `select 2`},
+		},
+		{
+			name: "comments",
+			sql:  "-- first; comment\nselect 1; /* a; /* nested; */ b; */ select 2; -- tail;",
+			want: []string{"-- first; comment\nselect 1", "/* a; /* nested; */ b; */ select 2"},
+		},
+		{
+			name: "dollar quoting",
+			sql: `create function f() returns int as $$ select 1; $$ language sql;
+do $body$ begin perform 'x$$;'; end $body$;
+prepare p as select $1; select a$b; select 3`,
+			want: []string{
+				`create function f() returns int as $$ select 1; $$ language sql`,
+				`do $body$ begin perform 'x$$;'; end $body$`,
+				`prepare p as select $1`,
+				`select a$b`,
+				`select 3`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitStatements(tt.sql))
+		})
+	}
+}
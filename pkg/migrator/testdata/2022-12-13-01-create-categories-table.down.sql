@@ -0,0 +1,4 @@
+ALTER TABLE "news"
+    DROP CONSTRAINT "Ref_news_to_categories";
+
+DROP TABLE "categories";
@@ -0,0 +1,4 @@
+CREATE OR REPLACE VIEW "vPublishedNews" AS
+SELECT "newsId", "title", "publishedAt"
+FROM "news"
+WHERE "publishedAt" IS NOT NULL;
@@ -0,0 +1,78 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+const (
+	// cannotConnectNow is SQLSTATE of error returned while database is starting up or shutting down.
+	cannotConnectNow = "57P03"
+
+	connectRetryBackoff    = 500 * time.Millisecond
+	connectRetryMaxBackoff = 5 * time.Second
+)
+
+// connectRetryPolicy returns retry policy and max wait time from config.
+func (m *Migrator) connectRetryPolicy() (retryPolicy, time.Duration, error) {
+	p := retryPolicy{attempts: m.cfg.ConnectRetries, backoff: connectRetryBackoff, maxBackoff: connectRetryMaxBackoff, jitter: 0.2}
+
+	var timeout time.Duration
+	if m.cfg.ConnectTimeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(m.cfg.ConnectTimeout); err != nil {
+			return p, 0, fmt.Errorf("invalid ConnectTimeout: %w", err)
+		}
+	}
+
+	return p, timeout, nil
+}
+
+// isRetryableConnectErr reports whether database may accept connections later:
+// network errors and "the database system is starting up" error.
+func isRetryableConnectErr(err error) bool {
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == cannotConnectNow
+	}
+
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// WaitForDB pings database with backoff until it accepts connections, each failed attempt is logged.
+// It waits up to ConnectTimeout and makes up to ConnectRetries attempts, see Config.
+// Errors returned by running database (e.g. authentication failed) are not retried.
+func (m *Migrator) WaitForDB(ctx context.Context) error {
+	p, timeout, err := m.connectRetryPolicy()
+	if err != nil {
+		return err
+	} else if m.pool == nil || timeout == 0 && p.attempts == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		err = m.pool.Ping(ctx)
+		if err == nil || !isRetryableConnectErr(err) {
+			return err
+		}
+
+		d := p.delay(attempt)
+		if p.attempts > 0 && attempt >= p.attempts || timeout > 0 && time.Now().Add(d).After(deadline) {
+			return fmt.Errorf("database is not available after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("database is not available (attempt %d): %v, retry in %v", attempt, err, d.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableConnectErr(t *testing.T) {
+	assert.True(t, isRetryableConnectErr(errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")))
+	assert.True(t, isRetryableConnectErr(fmt.Errorf("ping failed: %w", testPgError{code: cannotConnectNow})))
+	assert.False(t, isRetryableConnectErr(testPgError{code: "28P01"}))
+	assert.False(t, isRetryableConnectErr(context.Canceled))
+}
+
+func TestMigrator_WaitForDB(t *testing.T) {
+	// nothing listens on port 1
+	db := pg.Connect(&pg.Options{Addr: "127.0.0.1:1", User: "postgres"})
+	defer db.Close()
+
+	t.Run("no waiting", func(t *testing.T) {
+		m := NewMigrator(db, NewDefaultConfig(), "testdata")
+		require.NoError(t, m.WaitForDB(context.Background()))
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.ConnectRetries = 2
+		m := NewMigrator(db, cfg, "testdata")
+
+		err := m.WaitForDB(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "database is not available after 2 attempts")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.ConnectTimeout = "100ms"
+		m := NewMigrator(db, cfg, "testdata")
+
+		err := m.WaitForDB(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "database is not available after 1 attempts")
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.ConnectTimeout = "10"
+		m := NewMigrator(db, cfg, "testdata")
+
+		require.ErrorContains(t, m.WaitForDB(context.Background()), "invalid ConnectTimeout")
+	})
+}